@@ -0,0 +1,78 @@
+package horm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/challenai/horm/thrift/hbase"
+)
+
+// atomically append the values of string or []byte fields of model to the cells of a row:
+//
+//	db.Append(ctx, &Audit{Log: "login,"}, rowkey, []string{"Log"}, false)
+//
+// the suffixes are encoded with the codec of the fields,
+// with returnResults the resulting values are decoded into model.
+// fields is a slice rather than variadic so that per-call options can follow it, like the deltas of Increment.
+// versioned models are rejected, an append can't check their version, update them with Transact instead.
+func (h *DB) Append(ctx context.Context, model interface{}, rowkey string, fields []string, returnResults bool, opts ...Option) *DB {
+	tx := h.session()
+	tb, err := tableOf(model)
+	if err != nil {
+		tx.Error = err
+		return tx
+	}
+	schm, err := h.loadSchema(reflect.TypeOf(model).Elem())
+	if err != nil {
+		tx.Error = err
+		return tx
+	}
+	if schm.version != nil {
+		tx.Error = fmt.Errorf("%w: %T has a version field, append to it with Transact", ErrInvalidModel, model)
+		return tx
+	}
+	if len(fields) == 0 {
+		tx.Error = fmt.Errorf("nothing to append on row %s", rowkey)
+		return tx
+	}
+
+	value := reflect.ValueOf(model).Elem()
+	app := &hbase.TAppend{
+		Row:           []byte(rowkey),
+		Columns:       make([]*hbase.TColumnValue, 0, len(fields)),
+		ReturnResults: &returnResults,
+	}
+	appended := make([]*field, 0, len(fields))
+	for _, name := range fields {
+		f := schm.fieldByName(name)
+		if f == nil {
+			tx.Error = fmt.Errorf("%w: field %s is not mapped by %T", ErrUnknownColumn, name, model)
+			return tx
+		}
+		if f.typ.Kind() != reflect.String && f.typ != reflect.TypeOf([]byte{}) {
+			tx.Error = fmt.Errorf("field %s of %T can't be appended, it's neither a string nor []byte", name, model)
+			return tx
+		}
+		appended = append(appended, f)
+		app.Columns = append(app.Columns, f.columnValue(value))
+	}
+
+	h.newOptions(opts).applyAppend(app)
+	if app.CellVisibility == nil {
+		if app.CellVisibility, err = sharedVisibility(appended); err != nil {
+			tx.Error = err
+			return tx
+		}
+	}
+	result, err := tx.db.Append(ctx, []byte(fmt.Sprintf("%s:%s", tb.Namespace(), tb.TableName())), app)
+	if err != nil {
+		tx.Error = err
+		return tx
+	}
+	tx.RowsAffected = 1
+	if returnResults && result != nil {
+		tx.Error = h.retrieveValue(&value, result)
+	}
+	return tx
+}
@@ -1,7 +1,10 @@
 package client
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/apache/thrift/lib/go/thrift"
@@ -27,15 +30,45 @@ func (rt *RoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 	return http.DefaultTransport.RoundTrip(req)
 }
 
-// create a new hbase client
+// create a new hbase client, its calls run one at a time, see NewHBaseClientPool for concurrent calls.
 func NewHBaseClient(addr string, headers []Header) (*hbase.THBaseServiceClient, error) {
-	httpClient := http.Client{
+	thriftClient, err := newThriftClient(addr, newHTTPClient(headers))
+	if err != nil {
+		return nil, err
+	}
+	return hbase.NewTHBaseServiceClient(thriftClient), nil
+}
+
+// create a new hbase client running up to size calls concurrently, each over its own thrift transport.
+func NewHBaseClientPool(addr string, headers []Header, size int) (*hbase.THBaseServiceClient, error) {
+	if size <= 0 {
+		return nil, errors.New("size of the client pool should be positive")
+	}
+	// http clients are goroutine-safe, so the transports share one and its connections
+	httpClient := newHTTPClient(headers)
+	clients := make([]thrift.TClient, 0, size)
+	for i := 0; i < size; i++ {
+		c, err := newThriftClient(addr, httpClient)
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, c)
+	}
+	return hbase.NewTHBaseServiceClient(NewPool(clients...)), nil
+}
+
+func newHTTPClient(headers []Header) *http.Client {
+	return &http.Client{
 		Transport: &RoundTripper{
 			Headers: headers,
 		},
 		Timeout: time.Second * 10,
 	}
-	trans, err := thrift.NewTHttpClientWithOptions(addr, thrift.THttpClientOptions{Client: &httpClient})
+}
+
+// create a thrift client over a http transport.
+func newThriftClient(addr string, httpClient *http.Client) (thrift.TClient, error) {
+	trans, err := thrift.NewTHttpClientWithOptions(addr, thrift.THttpClientOptions{Client: httpClient})
 	if err != nil {
 		return nil, err
 	}
@@ -44,6 +77,52 @@ func NewHBaseClient(addr string, headers []Header) (*hbase.THBaseServiceClient,
 		return nil, err
 	}
 	proto := thrift.NewTBinaryProtocol(trans, false, false)
-	thriftClient := thrift.NewTStandardClient(proto, proto)
-	return hbase.NewTHBaseServiceClient(thriftClient), nil
+	return thrift.NewTStandardClient(proto, proto), nil
+}
+
+// SyncClient serializes calls on a thrift client, thrift transports are not safe for concurrent use.
+type SyncClient struct {
+	mu sync.Mutex
+	c  thrift.TClient
+}
+
+// create a goroutine-safe thrift client
+func NewSyncClient(c thrift.TClient) *SyncClient {
+	if sc, ok := c.(*SyncClient); ok {
+		return sc
+	}
+	return &SyncClient{c: c}
+}
+
+// Call implement thrift TClient interface
+func (sc *SyncClient) Call(ctx context.Context, method string, args, result thrift.TStruct) (thrift.ResponseMeta, error) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+	return sc.c.Call(ctx, method, args, result)
+}
+
+// Pool spreads calls over several thrift clients, each of which runs a single call at a time.
+type Pool struct {
+	clients chan thrift.TClient
+}
+
+// create a goroutine-safe thrift client running up to len(clients) calls concurrently,
+// the clients shouldn't be used by others.
+func NewPool(clients ...thrift.TClient) *Pool {
+	p := &Pool{clients: make(chan thrift.TClient, len(clients))}
+	for _, c := range clients {
+		p.clients <- c
+	}
+	return p
+}
+
+// Call implement thrift TClient interface, it waits for an idle client unless ctx is done.
+func (p *Pool) Call(ctx context.Context, method string, args, result thrift.TStruct) (thrift.ResponseMeta, error) {
+	select {
+	case c := <-p.clients:
+		defer func() { p.clients <- c }()
+		return c.Call(ctx, method, args, result)
+	case <-ctx.Done():
+		return thrift.ResponseMeta{}, ctx.Err()
+	}
 }
@@ -0,0 +1,102 @@
+package horm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/challenai/horm/thrift/hbase"
+)
+
+// Condition compares the column mapped by a go field with a value, it reads as "Field Op Value":
+//
+//	horm.Condition{Field: "Status", Op: hbase.TCompareOperator_EQUAL, Value: "expired"}
+//
+// the value is encoded with the codec of the field, a nil value with EQUAL means the column is absent.
+type Condition struct {
+	Field string
+	Op    hbase.TCompareOperator
+	Value interface{}
+}
+
+// resolve the column of the condition and encode its value.
+func (c Condition) build(schm *schema) (*field, []byte, error) {
+	f := schm.fieldByName(c.Field)
+	if f == nil {
+		return nil, nil, fmt.Errorf("%w: field %s is not mapped", ErrUnknownColumn, c.Field)
+	}
+	if c.Value == nil {
+		if c.Op != hbase.TCompareOperator_EQUAL {
+			return nil, nil, fmt.Errorf("condition on field %s compares nil with %s, only EQUAL is supported", c.Field, c.Op)
+		}
+		return f, nil, nil
+	}
+	if _, err := compareSymbol(c.Op); err != nil {
+		return nil, nil, err
+	}
+	b, err := f.encodeValue(c.Value)
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, b, nil
+}
+
+// HBase checks "value op cell" rather than "cell op value", so the operator is mirrored.
+func (c Condition) serverOp() hbase.TCompareOperator {
+	switch c.Op {
+	case hbase.TCompareOperator_LESS:
+		return hbase.TCompareOperator_GREATER
+	case hbase.TCompareOperator_LESS_OR_EQUAL:
+		return hbase.TCompareOperator_GREATER_OR_EQUAL
+	case hbase.TCompareOperator_GREATER:
+		return hbase.TCompareOperator_LESS
+	case hbase.TCompareOperator_GREATER_OR_EQUAL:
+		return hbase.TCompareOperator_LESS_OR_EQUAL
+	}
+	return c.Op
+}
+
+// delete a row only if the condition holds, RowsAffected is 0 if it doesn't.
+//
+//	db.DeleteIf(ctx, &Session{}, rowkey, horm.Condition{Field: "Status", Op: hbase.TCompareOperator_EQUAL, Value: "expired"})
+func (h *DB) DeleteIf(ctx context.Context, model interface{}, rowkey string, cond Condition, opts ...Option) *DB {
+	tx := h.session()
+	tb, err := tableOf(model)
+	if err != nil {
+		tx.Error = err
+		return tx
+	}
+	schm, err := h.loadSchema(reflect.TypeOf(model).Elem())
+	if err != nil {
+		tx.Error = err
+		return tx
+	}
+	f, value, err := cond.build(schm)
+	if err != nil {
+		tx.Error = err
+		return tx
+	}
+
+	table := []byte(fmt.Sprintf("%s:%s", tb.Namespace(), tb.TableName()))
+	row := []byte(rowkey)
+	del := &hbase.TDelete{Row: row}
+	h.newOptions(opts).applyDelete(del)
+	var ok bool
+	// checkAndDelete only checks equality, other operators go through checkAndMutate
+	if cond.Op == hbase.TCompareOperator_EQUAL {
+		ok, err = tx.db.CheckAndDelete(ctx, table, row, f.family, f.qualifier, value, del)
+	} else {
+		ok, err = tx.db.CheckAndMutate(ctx, table, row, f.family, f.qualifier, cond.serverOp(), value, &hbase.TRowMutations{
+			Row:       row,
+			Mutations: []*hbase.TMutation{{DeleteSingle: del}},
+		})
+	}
+	if err != nil {
+		tx.Error = err
+		return tx
+	}
+	if ok {
+		tx.RowsAffected = 1
+	}
+	return tx
+}
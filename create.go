@@ -0,0 +1,130 @@
+package horm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/challenai/horm/thrift/hbase"
+)
+
+// insert model only if the row doesn't exist yet, otherwise ErrRecordExists is returned.
+//
+// Create and Update tell whether a row exists by its sentinel column, which is the field tagged
+// with the sentinel option, or the version field, or the first mapped field of the model:
+//
+//	type User struct {
+//		*horm.Model
+//		Email string `horm:"info,email,sentinel"`
+//	}
+//
+// the sentinel column is always written by Create, even if it's not picked by selects,
+// and it should never be empty.
+func (h *DB) Create(ctx context.Context, model interface{}, selects []Column, opts ...Option) *DB {
+	tx := h.session()
+	tb, schm, value, put, err := h.prepareWrite(model, selects)
+	if err != nil {
+		tx.Error = err
+		return tx
+	}
+	f := schm.sentinel
+
+	col := f.columnValue(value)
+	if len(col.Value) == 0 {
+		tx.Error = fmt.Errorf("%w: sentinel field %s of %T can't be empty", ErrInvalidModel, f.name, model)
+		return tx
+	}
+	putColumn(put, col)
+	// the version is written after the sentinel, which may be the version column itself
+	var next reflect.Value
+	if schm.version != nil {
+		if !value.Field(schm.version.index).IsZero() {
+			tx.Error = fmt.Errorf("%w: version of %T should be zero to create it", ErrRecordExists, model)
+			return tx
+		}
+		next = nextVersion(schm.version, value, put)
+	}
+	h.newOptions(opts).applyPut(put, value)
+
+	// a nil value means the sentinel column should be absent
+	table := []byte(fmt.Sprintf("%s:%s", tb.Namespace(), tb.TableName()))
+	var ok bool
+	if puts := schm.splitPut(put); len(puts) == 1 {
+		ok, err = tx.db.CheckAndPut(ctx, table, put.Row, f.family, f.qualifier, nil, put)
+	} else {
+		ok, err = tx.db.CheckAndMutate(ctx, table, put.Row, f.family, f.qualifier, hbase.TCompareOperator_EQUAL, nil, putMutations(put.Row, puts))
+	}
+	if err != nil {
+		tx.Error = err
+		return tx
+	}
+	if !ok {
+		tx.Error = ErrRecordExists
+		return tx
+	}
+	if next.IsValid() {
+		value.Field(schm.version.index).Set(next)
+	}
+	tx.RowsAffected = 1
+	return tx
+}
+
+// update model only if the row exists by its sentinel column, otherwise ErrRecordNotFound is returned.
+// a versioned model should be read before, its version is checked and bumped like Set.
+func (h *DB) Update(ctx context.Context, model interface{}, selects []Column, opts ...Option) *DB {
+	tx := h.session()
+	tb, schm, value, put, err := h.prepareWrite(model, selects)
+	if err != nil {
+		tx.Error = err
+		return tx
+	}
+	h.newOptions(opts).applyPut(put, value)
+
+	table := []byte(fmt.Sprintf("%s:%s", tb.Namespace(), tb.TableName()))
+	if schm.version != nil {
+		if value.Field(schm.version.index).IsZero() {
+			tx.Error = fmt.Errorf("%w: version of %T is zero, read it before updating", ErrStaleObject, model)
+			return tx
+		}
+		if tx.Error = tx.putVersioned(ctx, table, schm, value, put); tx.Error == nil {
+			tx.RowsAffected = 1
+		}
+		return tx
+	}
+
+	// HBase ignores the operator of an empty value and checks the column is absent instead,
+	// "\x00 <= cell" only passes if the sentinel column exists and isn't empty
+	f := schm.sentinel
+	ok, err := tx.db.CheckAndMutate(ctx, table, put.Row, f.family, f.qualifier, hbase.TCompareOperator_LESS_OR_EQUAL, []byte{0x00}, putMutations(put.Row, schm.splitPut(put)))
+	if err != nil {
+		tx.Error = err
+		return tx
+	}
+	if !ok {
+		tx.Error = ErrRecordNotFound
+		return tx
+	}
+	tx.RowsAffected = 1
+	return tx
+}
+
+// resolve the table and schema of model and build its put.
+func (h *DB) prepareWrite(model interface{}, selects []Column) (Table, *schema, reflect.Value, *hbase.TPut, error) {
+	tb, err := tableOf(model)
+	if err != nil {
+		return nil, nil, reflect.Value{}, nil, err
+	}
+	value := reflect.ValueOf(model).Elem()
+	schm, err := h.loadSchema(value.Type())
+	if err != nil {
+		return nil, nil, reflect.Value{}, nil, err
+	}
+	if schm.sentinel == nil {
+		return nil, nil, reflect.Value{}, nil, fmt.Errorf("%w: %T doesn't map any column", ErrInvalidModel, model)
+	}
+	put := &hbase.TPut{}
+	if err = h.injectValue(&value, put, selects); err != nil {
+		return nil, nil, reflect.Value{}, nil, err
+	}
+	return tb, schm, value, put, nil
+}
@@ -0,0 +1,126 @@
+package horm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/challenai/horm/codec"
+	"github.com/challenai/horm/thrift/hbase"
+)
+
+func TestUpdateRequiresExistingSentinel(t *testing.T) {
+	db, fc := newFakeDB(true)
+	m := &account{Model: &Model{Rowkey: "a"}, Email: "a@b.c"}
+	if err := db.Update(context.Background(), m, nil).Error; err != nil {
+		t.Fatal(err)
+	}
+	args := fc.last(t, "checkAndMutate").(*hbase.THBaseServiceCheckAndMutateArgs)
+	if string(args.Family) != "info" || string(args.Qualifier) != "email" {
+		t.Errorf("condition on %s:%s, expected info:email", args.Family, args.Qualifier)
+	}
+	// an empty value would make HBase check the column is absent
+	if args.CompareOperator != hbase.TCompareOperator_LESS_OR_EQUAL || !bytes.Equal(args.Value, []byte{0x00}) {
+		t.Errorf("condition %s %q, expected LESS_OR_EQUAL \"\\x00\"", args.CompareOperator, args.Value)
+	}
+
+	fc.pass = false
+	if err := db.Update(context.Background(), m, nil).Error; !errors.Is(err, ErrRecordNotFound) {
+		t.Errorf("expected ErrRecordNotFound, but got %v", err)
+	}
+}
+
+func TestCreateVersionedWritesFirstVersion(t *testing.T) {
+	db, fc := newFakeDB(true)
+	m := &versionedAccount{Model: &Model{Rowkey: "a"}, Email: "a@b.c"}
+	if err := db.Create(context.Background(), m, nil).Error; err != nil {
+		t.Fatal(err)
+	}
+	args := fc.last(t, "checkAndPut").(*hbase.THBaseServiceCheckAndPutArgs)
+	// the sentinel defaults to the version column, which should be absent
+	if string(args.Qualifier) != "ver" || args.Value != nil {
+		t.Errorf("condition on %s = %q, expected ver to be absent", args.Qualifier, args.Value)
+	}
+	c := &codec.DefaultCodec{}
+	if ver := columnOf(args.Tput, "info", "ver"); !bytes.Equal(ver, c.EncodeInt(1)) {
+		t.Errorf("wrote version %v, expected 1", ver)
+	}
+	if m.Ver != 1 {
+		t.Errorf("model version is %d, expected 1", m.Ver)
+	}
+
+	fc.pass = false
+	m = &versionedAccount{Model: &Model{Rowkey: "a"}, Email: "a@b.c"}
+	if err := db.Create(context.Background(), m, nil).Error; !errors.Is(err, ErrRecordExists) {
+		t.Errorf("expected ErrRecordExists, but got %v", err)
+	}
+	if m.Ver != 0 {
+		t.Errorf("model version is %d after a failed create, expected 0", m.Ver)
+	}
+}
+
+func TestSetVersionedChecksReadVersion(t *testing.T) {
+	db, fc := newFakeDB(true)
+	c := &codec.DefaultCodec{}
+	m := &versionedAccount{Model: &Model{Rowkey: "a"}, Email: "a@b.c", Ver: 3}
+	if err := db.Set(context.Background(), m, nil).Error; err != nil {
+		t.Fatal(err)
+	}
+	args := fc.last(t, "checkAndPut").(*hbase.THBaseServiceCheckAndPutArgs)
+	if string(args.Qualifier) != "ver" || !bytes.Equal(args.Value, c.EncodeInt(3)) {
+		t.Errorf("condition on %s = %v, expected ver = 3", args.Qualifier, args.Value)
+	}
+	if ver := columnOf(args.Tput, "info", "ver"); !bytes.Equal(ver, c.EncodeInt(4)) {
+		t.Errorf("wrote version %v, expected 4", ver)
+	}
+	if m.Ver != 4 {
+		t.Errorf("model version is %d, expected 4", m.Ver)
+	}
+
+	fc.pass = false
+	if err := db.Set(context.Background(), m, nil).Error; !errors.Is(err, ErrStaleObject) {
+		t.Errorf("expected ErrStaleObject, but got %v", err)
+	}
+	if m.Ver != 4 {
+		t.Errorf("model version is %d after a conflict, expected 4", m.Ver)
+	}
+}
+
+func TestDeleteIfMirrorsOperator(t *testing.T) {
+	c := &codec.DefaultCodec{}
+	cases := []struct {
+		op   hbase.TCompareOperator
+		sent hbase.TCompareOperator
+	}{
+		{hbase.TCompareOperator_LESS, hbase.TCompareOperator_GREATER},
+		{hbase.TCompareOperator_LESS_OR_EQUAL, hbase.TCompareOperator_GREATER_OR_EQUAL},
+		{hbase.TCompareOperator_GREATER, hbase.TCompareOperator_LESS},
+		{hbase.TCompareOperator_GREATER_OR_EQUAL, hbase.TCompareOperator_LESS_OR_EQUAL},
+		{hbase.TCompareOperator_NOT_EQUAL, hbase.TCompareOperator_NOT_EQUAL},
+	}
+	for _, tc := range cases {
+		db, fc := newFakeDB(true)
+		tx := db.DeleteIf(context.Background(), &account{}, "a", Condition{Field: "Age", Op: tc.op, Value: 18})
+		if tx.Error != nil {
+			t.Fatal(tx.Error)
+		}
+		args := fc.last(t, "checkAndMutate").(*hbase.THBaseServiceCheckAndMutateArgs)
+		if args.CompareOperator != tc.sent || !bytes.Equal(args.Value, c.EncodeInt(18)) {
+			t.Errorf("Age %s 18 sent as %s %v, expected %s", tc.op, args.CompareOperator, args.Value, tc.sent)
+		}
+		if tx.RowsAffected != 1 {
+			t.Errorf("RowsAffected is %d, expected 1", tx.RowsAffected)
+		}
+	}
+
+	db, fc := newFakeDB(false)
+	tx := db.DeleteIf(context.Background(), &account{}, "a", Condition{Field: "Email", Op: hbase.TCompareOperator_EQUAL, Value: "a@b.c"})
+	args := fc.last(t, "checkAndDelete").(*hbase.THBaseServiceCheckAndDeleteArgs)
+	if string(args.Qualifier) != "email" || string(args.Value) != "a@b.c" {
+		t.Errorf("condition on %s = %q, expected email = a@b.c", args.Qualifier, args.Value)
+	}
+	if tx.Error != nil || tx.RowsAffected != 0 {
+		t.Errorf("failed condition returned %v with %d rows affected", tx.Error, tx.RowsAffected)
+	}
+}
@@ -1,14 +1,48 @@
 package horm
 
+import "time"
+
 // Model should implement Table interface to specify the namespace and table name.
 type Table interface {
 	Namespace() string
 	TableName() string
 }
 
+// Model can implement Families to declare the options of its column families,
+// which are used by AutoMigrate. families only declared here are created as well.
+//
+//	func (*Event) FamilyOptions() map[string]horm.FamilyOptions {
+//		return map[string]horm.FamilyOptions{
+//			"log": {MaxVersions: 5, TTL: 7 * 24 * time.Hour, Compression: "GZ"},
+//		}
+//	}
+type Families interface {
+	FamilyOptions() map[string]FamilyOptions
+}
+
+// FamilyOptions describes a column family, zero fields are left to the server defaults.
+// Compression, BloomFilter and DataBlockEncoding are the HBase names like "SNAPPY", "ROWCOL" and "FAST_DIFF".
+type FamilyOptions struct {
+	MaxVersions       int32
+	MinVersions       int32
+	TTL               time.Duration // rounded down to seconds
+	Compression       string
+	BloomFilter       string
+	BlockSize         int32
+	InMemory          bool
+	DataBlockEncoding string
+}
+
 // base model for every hbase model
 type Model struct {
 	Rowkey string
+	// Timestamp is the latest cell timestamp of the row after a read
+	Timestamp time.Time
+	// FieldTimestamps maps go field names to the timestamps of their cells after a read,
+	// it's only filled if *horm.Model is embedded with the `horm:"timestamps"` tag
+	FieldTimestamps map[string]time.Time
+	// Stale tells whether the row was read from a secondary replica, which may lag behind the primary one
+	Stale bool
 }
 
 // column is a column in HBase column family, used to pick columns to query
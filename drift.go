@@ -0,0 +1,328 @@
+package horm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/challenai/horm/thrift/hbase"
+)
+
+// SchemaDiff is the difference between the tables expected by models and the live ones, read it with DB.CheckSchema.
+type SchemaDiff struct {
+	Tables []TableDiff // only the tables which differ
+}
+
+// TableDiff is the difference of a single table.
+type TableDiff struct {
+	Namespace        string
+	Table            string
+	MissingNamespace bool
+	MissingTable     bool
+	MissingFamilies  []string
+	ExtraFamilies    []string // live families mapped by none of the models, they're never dropped
+	Options          []OptionDiff
+
+	expected []*hbase.TColumnFamilyDescriptor
+	live     map[string]*hbase.TColumnFamilyDescriptor
+}
+
+// OptionDiff is a family option declared by the Families interface which differs from the live one.
+type OptionDiff struct {
+	Family   string
+	Option   string // name of the FamilyOptions field
+	Expected string
+	Actual   string
+}
+
+// SchemaChange is a single admin call which brings a table closer to its models.
+type SchemaChange struct {
+	Op        string // one of createNamespace, createTable, addColumnFamily and modifyColumnFamily
+	Namespace string
+	Table     string
+	// all the families of the table for createTable, otherwise the added or modified family
+	Families []*hbase.TColumnFamilyDescriptor
+}
+
+func (c SchemaChange) String() string {
+	if c.Op == "createNamespace" {
+		return fmt.Sprintf("%s %s", c.Op, c.Namespace)
+	}
+	names := make([]string, 0, len(c.Families))
+	for _, f := range c.Families {
+		names = append(names, string(f.Name))
+	}
+	return fmt.Sprintf("%s %s:%s %s", c.Op, c.Namespace, c.Table, strings.Join(names, ","))
+}
+
+// family options compared by CheckSchema, FamilyOptions field name -> TColumnFamilyDescriptor field name.
+var familyOptionFields = [][2]string{
+	{"MaxVersions", "MaxVersions"},
+	{"MinVersions", "MinVersions"},
+	{"TTL", "TimeToLive"},
+	{"Compression", "CompressionType"},
+	{"BloomFilter", "BloomnFilterType"},
+	{"BlockSize", "BlockSize"},
+	{"InMemory", "InMemory"},
+	{"DataBlockEncoding", "DataBlockEncoding"},
+}
+
+// compare the tables, families and family options expected by models with the live table descriptors,
+// so that a deployment can be stopped before running against an unmigrated cluster:
+//
+//	diff, err := db.CheckSchema(ctx, &User{}, &Order{})
+//	if err == nil && !diff.Empty() {
+//		err = fmt.Errorf("schema drift: %s", diff)
+//	}
+//
+// models sharing a table are checked together. only the options declared by the Families interface are compared.
+func (h *DB) CheckSchema(ctx context.Context, models ...interface{}) (*SchemaDiff, error) {
+	tx := h.session()
+	tables, err := h.expectedTables(models)
+	if err != nil {
+		return nil, err
+	}
+	namespaces, err := tx.db.ListNamespaces(ctx)
+	if err != nil {
+		return nil, err
+	}
+	existing := map[string]bool{}
+	for _, ns := range namespaces {
+		existing[ns] = true
+	}
+
+	diff := &SchemaDiff{}
+	for _, td := range tables {
+		td.MissingNamespace = !existing[td.Namespace]
+		tableName := &hbase.TTableName{
+			Ns:        []byte(td.Namespace),
+			Qualifier: []byte(td.Table),
+		}
+		ok := false
+		if !td.MissingNamespace {
+			if ok, err = tx.db.TableExists(ctx, tableName); err != nil {
+				return nil, err
+			}
+		}
+		if !ok {
+			td.MissingTable = true
+			diff.Tables = append(diff.Tables, *td)
+			continue
+		}
+
+		desc, err := tx.db.GetTableDescriptor(ctx, tableName)
+		if err != nil {
+			return nil, err
+		}
+		td.live = map[string]*hbase.TColumnFamilyDescriptor{}
+		for _, col := range desc.Columns {
+			td.live[string(col.Name)] = col
+		}
+		expected := map[string]bool{}
+		for _, want := range td.expected {
+			expected[string(want.Name)] = true
+			got := td.live[string(want.Name)]
+			if got == nil {
+				td.MissingFamilies = append(td.MissingFamilies, string(want.Name))
+				continue
+			}
+			td.Options = append(td.Options, diffFamily(want, got)...)
+		}
+		for _, col := range desc.Columns {
+			if !expected[string(col.Name)] {
+				td.ExtraFamilies = append(td.ExtraFamilies, string(col.Name))
+			}
+		}
+		if len(td.MissingFamilies) > 0 || len(td.ExtraFamilies) > 0 || len(td.Options) > 0 {
+			diff.Tables = append(diff.Tables, *td)
+		}
+	}
+	return diff, nil
+}
+
+// Empty tells whether the live tables match the models.
+// extra families alone make a diff non-empty, though Plan never drops them.
+func (d *SchemaDiff) Empty() bool {
+	return len(d.Tables) == 0
+}
+
+func (d *SchemaDiff) String() string {
+	var parts []string
+	for _, td := range d.Tables {
+		name := td.Namespace + ":" + td.Table
+		switch {
+		case td.MissingNamespace:
+			parts = append(parts, fmt.Sprintf("namespace %s is missing", td.Namespace))
+		case td.MissingTable:
+			parts = append(parts, fmt.Sprintf("table %s is missing", name))
+		}
+		for _, f := range td.MissingFamilies {
+			parts = append(parts, fmt.Sprintf("family %s of %s is missing", f, name))
+		}
+		for _, f := range td.ExtraFamilies {
+			parts = append(parts, fmt.Sprintf("family %s of %s isn't mapped", f, name))
+		}
+		for _, o := range td.Options {
+			parts = append(parts, fmt.Sprintf("%s of family %s of %s is %s, expected %s", o.Option, o.Family, name, o.Actual, o.Expected))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Plan lists the admin calls which make the live tables match the models, without dropping anything.
+// a modified family keeps its live options which aren't declared by the model.
+func (d *SchemaDiff) Plan() []SchemaChange {
+	var plan []SchemaChange
+	// tables of a missing namespace all report it, but it's only created once
+	created := map[string]bool{}
+	for _, td := range d.Tables {
+		if td.MissingNamespace && !created[td.Namespace] {
+			created[td.Namespace] = true
+			plan = append(plan, SchemaChange{Op: "createNamespace", Namespace: td.Namespace})
+		}
+		if td.MissingTable {
+			plan = append(plan, SchemaChange{Op: "createTable", Namespace: td.Namespace, Table: td.Table, Families: td.expected})
+			continue
+		}
+		for _, want := range td.expected {
+			got := td.live[string(want.Name)]
+			if got == nil {
+				plan = append(plan, SchemaChange{Op: "addColumnFamily", Namespace: td.Namespace, Table: td.Table, Families: []*hbase.TColumnFamilyDescriptor{want}})
+				continue
+			}
+			if len(diffFamily(want, got)) > 0 {
+				plan = append(plan, SchemaChange{Op: "modifyColumnFamily", Namespace: td.Namespace, Table: td.Table, Families: []*hbase.TColumnFamilyDescriptor{mergeFamily(want, got)}})
+			}
+		}
+	}
+	return plan
+}
+
+// ApplySchema runs the changes of a plan in order, it stops at the first failed change.
+// RowsAffected is the number of changes applied.
+func (h *DB) ApplySchema(ctx context.Context, plan []SchemaChange) *DB {
+	tx := h.session()
+	for _, c := range plan {
+		tableName := &hbase.TTableName{
+			Ns:        []byte(c.Namespace),
+			Qualifier: []byte(c.Table),
+		}
+		var err error
+		switch c.Op {
+		case "createNamespace":
+			err = tx.db.CreateNamespace(ctx, &hbase.TNamespaceDescriptor{Name: c.Namespace})
+		case "createTable":
+			err = tx.db.CreateTable(ctx, &hbase.TTableDescriptor{TableName: tableName, Columns: c.Families}, nil)
+		case "addColumnFamily", "modifyColumnFamily":
+			if len(c.Families) != 1 {
+				err = fmt.Errorf("%s takes a single family, but got %d", c.Op, len(c.Families))
+			} else if c.Op == "addColumnFamily" {
+				err = tx.db.AddColumnFamily(ctx, tableName, c.Families[0])
+			} else {
+				err = tx.db.ModifyColumnFamily(ctx, tableName, c.Families[0])
+			}
+		default:
+			err = fmt.Errorf("unknown schema change %q", c.Op)
+		}
+		if err != nil {
+			tx.Error = fmt.Errorf("%s: %w", c, err)
+			return tx
+		}
+		tx.RowsAffected++
+	}
+	return tx
+}
+
+// group the families expected by models by table in the order of models.
+// a family declared with different options by two models sharing a table is an error.
+func (h *DB) expectedTables(models []interface{}) ([]*TableDiff, error) {
+	var tables []*TableDiff
+	byName := map[string]*TableDiff{}
+	for _, model := range models {
+		tb, err := tableOf(model)
+		if err != nil {
+			return nil, err
+		}
+		schm, err := h.loadSchema(reflect.TypeOf(model).Elem())
+		if err != nil {
+			return nil, err
+		}
+		families, err := familiesOf(model, schm)
+		if err != nil {
+			return nil, err
+		}
+		name := tb.Namespace() + ":" + tb.TableName()
+		td := byName[name]
+		if td == nil {
+			td = &TableDiff{Namespace: tb.Namespace(), Table: tb.TableName()}
+			byName[name] = td
+			tables = append(tables, td)
+		}
+		for _, f := range families {
+			i := 0
+			for i < len(td.expected) && string(td.expected[i].Name) != string(f.Name) {
+				i++
+			}
+			// a family without options yields to the declared one
+			switch {
+			case i == len(td.expected):
+				td.expected = append(td.expected, f)
+			case isBareFamily(f) || reflect.DeepEqual(td.expected[i], f):
+			case isBareFamily(td.expected[i]):
+				td.expected[i] = f
+			default:
+				return nil, fmt.Errorf("%w: family %s of %s is declared with different options by %T", ErrInvalidModel, f.Name, name, model)
+			}
+		}
+	}
+	return tables, nil
+}
+
+// whether a family descriptor leaves all the options to the server.
+func isBareFamily(f *hbase.TColumnFamilyDescriptor) bool {
+	return reflect.DeepEqual(f, &hbase.TColumnFamilyDescriptor{Name: f.Name})
+}
+
+// the declared options of want which differ from got.
+func diffFamily(want, got *hbase.TColumnFamilyDescriptor) []OptionDiff {
+	var diffs []OptionDiff
+	w, g := reflect.ValueOf(want).Elem(), reflect.ValueOf(got).Elem()
+	for _, names := range familyOptionFields {
+		wf, gf := w.FieldByName(names[1]), g.FieldByName(names[1])
+		if wf.IsNil() || (!gf.IsNil() && wf.Elem().Interface() == gf.Elem().Interface()) {
+			continue
+		}
+		diffs = append(diffs, OptionDiff{
+			Family:   string(want.Name),
+			Option:   names[0],
+			Expected: formatFamilyOption(names[1], wf),
+			Actual:   formatFamilyOption(names[1], gf),
+		})
+	}
+	return diffs
+}
+
+// format an option of a family descriptor for humans.
+func formatFamilyOption(name string, v reflect.Value) string {
+	if v.IsNil() {
+		return "unset"
+	}
+	if name == "TimeToLive" {
+		return (time.Duration(v.Elem().Int()) * time.Second).String()
+	}
+	return fmt.Sprint(v.Elem().Interface())
+}
+
+// the live family got with the declared options of want.
+func mergeFamily(want, got *hbase.TColumnFamilyDescriptor) *hbase.TColumnFamilyDescriptor {
+	merged := *got
+	m, w := reflect.ValueOf(&merged).Elem(), reflect.ValueOf(want).Elem()
+	for _, names := range familyOptionFields {
+		if wf := w.FieldByName(names[1]); !wf.IsNil() {
+			m.FieldByName(names[1]).Set(wf)
+		}
+	}
+	return &merged
+}
@@ -0,0 +1,63 @@
+package horm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/challenai/horm/thrift/hbase"
+)
+
+type shopOrder struct {
+	*Model
+	Total int64 `horm:"f,total"`
+}
+
+func (*shopOrder) Namespace() string { return "shop" }
+func (*shopOrder) TableName() string { return "t1" }
+
+type shopItem struct {
+	*Model
+	Name string `horm:"f,name"`
+}
+
+func (*shopItem) Namespace() string { return "shop" }
+func (*shopItem) TableName() string { return "t2" }
+
+func TestPlanCreatesMissingNamespaceOnce(t *testing.T) {
+	db, fc := newFakeDB(true)
+	fc.answer = func(ctx context.Context, method string, args, result thrift.TStruct) error {
+		switch r := result.(type) {
+		case *hbase.THBaseServiceListNamespacesResult:
+			r.Success = []string{"default"}
+		case *hbase.THBaseServiceTableExistsResult:
+			t.Errorf("tableExists called on a missing namespace")
+		}
+		return nil
+	}
+	diff, err := db.CheckSchema(context.Background(), &shopOrder{}, &shopItem{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diff.Tables) != 2 || !diff.Tables[0].MissingNamespace || !diff.Tables[1].MissingTable {
+		t.Fatalf("unexpected diff %s", diff)
+	}
+
+	var got []string
+	for _, c := range diff.Plan() {
+		got = append(got, c.String())
+	}
+	expected := []string{"createNamespace shop", "createTable shop:t1 f", "createTable shop:t2 f"}
+	if len(got) != len(expected) {
+		t.Fatalf("plan %q, expected %q", got, expected)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("change %d is %q, expected %q", i, got[i], expected[i])
+		}
+	}
+
+	if tx := db.ApplySchema(context.Background(), diff.Plan()); tx.Error != nil || tx.RowsAffected != 3 {
+		t.Errorf("ApplySchema returned %v with %d changes applied", tx.Error, tx.RowsAffected)
+	}
+}
@@ -0,0 +1,62 @@
+package horm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrRecordNotFound is returned when the requested row doesn't exist.
+	ErrRecordNotFound = errors.New("record not found")
+	// ErrInvalidModel is returned when a model or a list of models can't be mapped to a HBase table.
+	ErrInvalidModel = errors.New("invalid model")
+	// ErrBadTag is returned when a horm struct tag is malformed.
+	ErrBadTag = errors.New("bad horm tag")
+	// ErrUnknownColumn is returned when a picked column isn't mapped by the model.
+	ErrUnknownColumn = errors.New("unknown column")
+	// ErrRecordExists is returned when creating a row which already exists.
+	ErrRecordExists = errors.New("record already exists")
+	// ErrStaleObject is returned when a versioned model was changed by others since it was read.
+	ErrStaleObject = errors.New("stale object")
+)
+
+// DeleteError reports the rows which the server could not delete.
+type DeleteError struct {
+	Rowkeys []string
+}
+
+func (e *DeleteError) Error() string {
+	return fmt.Sprintf("failed to delete %d rows: %s", len(e.Rowkeys), strings.Join(e.Rowkeys, ", "))
+}
+
+// MissingRowsError reports the rowkeys which don't exist in HBase.
+type MissingRowsError struct {
+	Rowkeys []string
+}
+
+func (e *MissingRowsError) Error() string {
+	return fmt.Sprintf("%d rows not found: %s", len(e.Rowkeys), strings.Join(e.Rowkeys, ", "))
+}
+
+// Is makes errors.Is(err, ErrRecordNotFound) hold for missing rows.
+func (e *MissingRowsError) Is(target error) bool {
+	return target == ErrRecordNotFound
+}
+
+// DecodeError reports a cell which can't be decoded into its struct field.
+type DecodeError struct {
+	Rowkey    string
+	Family    string
+	Qualifier string
+	Field     string
+	Err       error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("failed to decode column %s:%s of row %s into field %s: %v", e.Family, e.Qualifier, e.Rowkey, e.Field, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
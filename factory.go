@@ -24,3 +24,13 @@ func NewHBaseCodec(addr string, headers []client.Header, codec c.Codec) (*DB, er
 	hb := NewDB(client, codec)
 	return hb, nil
 }
+
+// NewHBasePool create a new HBase DB running up to size calls concurrently
+func NewHBasePool(addr string, headers []client.Header, size int) (*DB, error) {
+	client, err := client.NewHBaseClientPool(addr, headers, size)
+	if err != nil {
+		return nil, err
+	}
+	hb := NewDB(client, &c.DefaultCodec{})
+	return hb, nil
+}
@@ -0,0 +1,232 @@
+package horm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/challenai/horm/thrift/hbase"
+)
+
+// Expr is a filter expression which renders to HBase filter language.
+// columns are referenced by go field names and comparison values are encoded with the codec of the DB,
+// so they match the bytes written by Set.
+//
+//	db.Model(&User{}).Where(horm.And(horm.Ge("Age", 18), horm.RowPrefix("u_"))).Find(ctx, &users)
+//
+// values are compared as bytes, so the order of negative numbers and floats follows their encoding.
+type Expr interface {
+	render(schm *schema) (string, error)
+}
+
+type exprFunc func(schm *schema) (string, error)
+
+func (f exprFunc) render(schm *schema) (string, error) {
+	return f(schm)
+}
+
+// Raw uses a hand-written HBase filter language string as it is.
+func Raw(filter string) Expr {
+	return exprFunc(func(*schema) (string, error) {
+		if filter == "" {
+			return "", errors.New("empty raw filter")
+		}
+		return filter, nil
+	})
+}
+
+// ColumnValue keeps rows whose column mapped by field compares to value with op,
+// rows without the column are dropped.
+func ColumnValue(field string, op hbase.TCompareOperator, value interface{}) Expr {
+	return exprFunc(func(schm *schema) (string, error) {
+		f := schm.fieldByName(field)
+		if f == nil {
+			return "", fmt.Errorf("%w: field %s is not mapped", ErrUnknownColumn, field)
+		}
+		sym, err := compareSymbol(op)
+		if err != nil {
+			return "", err
+		}
+		b, err := f.encodeValue(value)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("SingleColumnValueFilter(%s, %s, %s, %s, true, true)",
+			quoteFilterArg(string(f.family)), quoteFilterArg(string(f.qualifier)), sym, quoteFilterArg("binary:"+string(b))), nil
+	})
+}
+
+// Eq keeps rows whose field equals value.
+func Eq(field string, value interface{}) Expr {
+	return ColumnValue(field, hbase.TCompareOperator_EQUAL, value)
+}
+
+// Ne keeps rows whose field doesn't equal value.
+func Ne(field string, value interface{}) Expr {
+	return ColumnValue(field, hbase.TCompareOperator_NOT_EQUAL, value)
+}
+
+// Lt keeps rows whose field is less than value.
+func Lt(field string, value interface{}) Expr {
+	return ColumnValue(field, hbase.TCompareOperator_LESS, value)
+}
+
+// Le keeps rows whose field is less than or equal to value.
+func Le(field string, value interface{}) Expr {
+	return ColumnValue(field, hbase.TCompareOperator_LESS_OR_EQUAL, value)
+}
+
+// Gt keeps rows whose field is greater than value.
+func Gt(field string, value interface{}) Expr {
+	return ColumnValue(field, hbase.TCompareOperator_GREATER, value)
+}
+
+// Ge keeps rows whose field is greater than or equal to value.
+func Ge(field string, value interface{}) Expr {
+	return ColumnValue(field, hbase.TCompareOperator_GREATER_OR_EQUAL, value)
+}
+
+// RowPrefix keeps rows whose rowkey starts with prefix.
+func RowPrefix(prefix string) Expr {
+	return exprFunc(func(*schema) (string, error) {
+		return fmt.Sprintf("PrefixFilter(%s)", quoteFilterArg(prefix)), nil
+	})
+}
+
+// RowRange keeps rows from start (inclusive) to stop (exclusive), an empty string means unbounded.
+func RowRange(start, stop string) Expr {
+	return exprFunc(func(*schema) (string, error) {
+		var conds []string
+		if start != "" {
+			conds = append(conds, fmt.Sprintf("RowFilter(>=, %s)", quoteFilterArg("binary:"+start)))
+		}
+		if stop != "" {
+			conds = append(conds, fmt.Sprintf("RowFilter(<, %s)", quoteFilterArg("binary:"+stop)))
+		}
+		if len(conds) == 0 {
+			return "", errors.New("row range should have a start or a stop row")
+		}
+		return joinFilters(conds, "AND"), nil
+	})
+}
+
+// Qualifier keeps cells whose qualifier compares to qualifier with op.
+func Qualifier(op hbase.TCompareOperator, qualifier string) Expr {
+	return exprFunc(func(*schema) (string, error) {
+		sym, err := compareSymbol(op)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("QualifierFilter(%s, %s)", sym, quoteFilterArg("binary:"+qualifier)), nil
+	})
+}
+
+// KeyOnly strips the values of cells, only rowkeys and column names are returned.
+func KeyOnly() Expr {
+	return exprFunc(func(*schema) (string, error) {
+		return "KeyOnlyFilter()", nil
+	})
+}
+
+// Page returns at most n rows per region server, use Query.Limit for an exact limit.
+func Page(n int64) Expr {
+	return exprFunc(func(*schema) (string, error) {
+		if n <= 0 {
+			return "", fmt.Errorf("page size should be positive, but got %d", n)
+		}
+		return fmt.Sprintf("PageFilter(%d)", n), nil
+	})
+}
+
+// And keeps rows matching all of the expressions.
+func And(exprs ...Expr) Expr {
+	return filterList("AND", exprs)
+}
+
+// Or keeps rows matching any of the expressions.
+func Or(exprs ...Expr) Expr {
+	return filterList("OR", exprs)
+}
+
+// Skip drops the whole row if any of its cells doesn't match expr.
+func Skip(expr Expr) Expr {
+	return unaryFilter("SKIP", expr)
+}
+
+// While stops the scan at the first row which doesn't match expr.
+func While(expr Expr) Expr {
+	return unaryFilter("WHILE", expr)
+}
+
+func filterList(op string, exprs []Expr) Expr {
+	return exprFunc(func(schm *schema) (string, error) {
+		if len(exprs) == 0 {
+			return "", fmt.Errorf("%s needs at least one filter", op)
+		}
+		conds := make([]string, 0, len(exprs))
+		for _, e := range exprs {
+			s, err := e.render(schm)
+			if err != nil {
+				return "", err
+			}
+			conds = append(conds, s)
+		}
+		return joinFilters(conds, op), nil
+	})
+}
+
+func unaryFilter(op string, expr Expr) Expr {
+	return exprFunc(func(schm *schema) (string, error) {
+		s, err := expr.render(schm)
+		if err != nil {
+			return "", err
+		}
+		return fmt.Sprintf("%s (%s)", op, s), nil
+	})
+}
+
+// join filters with a binary operator, every operand is parenthesized to keep the precedence.
+func joinFilters(conds []string, op string) string {
+	if len(conds) == 1 {
+		return conds[0]
+	}
+	return "(" + strings.Join(conds, ") "+op+" (") + ")"
+}
+
+// FilterString renders expr against model to a HBase filter language string,
+// it can be used as Filter.FilterString of Find.
+func (h *DB) FilterString(model interface{}, expr Expr) (string, error) {
+	if _, err := tableOf(model); err != nil {
+		return "", err
+	}
+	schm, err := h.loadSchema(reflect.TypeOf(model).Elem())
+	if err != nil {
+		return "", err
+	}
+	return expr.render(schm)
+}
+
+// get the symbol of a compare operator in HBase filter language.
+func compareSymbol(op hbase.TCompareOperator) (string, error) {
+	switch op {
+	case hbase.TCompareOperator_LESS:
+		return "<", nil
+	case hbase.TCompareOperator_LESS_OR_EQUAL:
+		return "<=", nil
+	case hbase.TCompareOperator_EQUAL:
+		return "=", nil
+	case hbase.TCompareOperator_NOT_EQUAL:
+		return "!=", nil
+	case hbase.TCompareOperator_GREATER_OR_EQUAL:
+		return ">=", nil
+	case hbase.TCompareOperator_GREATER:
+		return ">", nil
+	}
+	return "", fmt.Errorf("compare operator %s is not supported by filter language", op)
+}
+
+// quote a string argument of HBase filter language, single quotes are escaped by doubling them.
+func quoteFilterArg(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
@@ -0,0 +1,104 @@
+package horm
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/challenai/horm/thrift/hbase"
+)
+
+type metric struct {
+	*Model
+	Small int8    `horm:"m,small"`
+	Count uint32  `horm:"m,count"`
+	Ratio float32 `horm:"m,ratio"`
+	Total float64 `horm:"m,total"`
+}
+
+func (*metric) Namespace() string { return "test" }
+func (*metric) TableName() string { return "metric" }
+
+func TestFilterValuesConvertWithoutLoss(t *testing.T) {
+	db, _ := newFakeDB(true)
+	valid := []Expr{
+		Eq("Small", 100),
+		Eq("Small", int64(-128)),
+		Eq("Count", 7),
+		Eq("Count", uint64(math.MaxUint32)),
+		Eq("Ratio", 1.5),
+		Eq("Total", int64(1)<<53),
+	}
+	for _, expr := range valid {
+		if _, err := db.FilterString(&metric{}, expr); err != nil {
+			t.Errorf("unexpected error %v", err)
+		}
+	}
+	invalid := []Expr{
+		Eq("Small", 1.5),
+		Eq("Small", 1.0),
+		Eq("Small", int64(300)),
+		Eq("Count", -1),
+		Eq("Count", uint64(math.MaxUint32)+1),
+		Eq("Ratio", math.MaxFloat64),
+		Eq("Total", int64(1)<<53+1),
+	}
+	for i, expr := range invalid {
+		if s, err := db.FilterString(&metric{}, expr); err == nil {
+			t.Errorf("invalid filter %d rendered as %s", i, s)
+		}
+	}
+	cond := Condition{Field: "Small", Op: hbase.TCompareOperator_EQUAL, Value: 2.5}
+	if err := db.DeleteIf(context.Background(), &metric{}, "a", cond).Error; err == nil {
+		t.Error("condition with a lossy value should fail")
+	}
+}
+
+func TestFilterString(t *testing.T) {
+	db, _ := newFakeDB(true)
+	email := "SingleColumnValueFilter('info', 'email', =, 'binary:a@b.c', true, true)"
+	cases := []struct {
+		expr     Expr
+		expected string
+	}{
+		{Eq("Email", "a@b.c"), email},
+		{Ge("Email", "a"), "SingleColumnValueFilter('info', 'email', >=, 'binary:a', true, true)"},
+		{Ne("Email", "o'brien"), "SingleColumnValueFilter('info', 'email', !=, 'binary:o''brien', true, true)"},
+		{RowPrefix("it's_"), "PrefixFilter('it''s_')"},
+		{And(Eq("Email", "a@b.c")), email},
+		{And(Eq("Email", "a@b.c"), RowPrefix("u_")), "(" + email + ") AND (PrefixFilter('u_'))"},
+		{Or(And(KeyOnly(), Page(10)), RowPrefix("u_")), "((KeyOnlyFilter()) AND (PageFilter(10))) OR (PrefixFilter('u_'))"},
+		{Skip(Qualifier(hbase.TCompareOperator_LESS, "z")), "SKIP (QualifierFilter(<, 'binary:z'))"},
+		{While(RowRange("a", "b")), "WHILE ((RowFilter(>=, 'binary:a')) AND (RowFilter(<, 'binary:b')))"},
+		{RowRange("", "b"), "RowFilter(<, 'binary:b')"},
+		{Raw("FirstKeyOnlyFilter()"), "FirstKeyOnlyFilter()"},
+	}
+	for _, c := range cases {
+		got, err := db.FilterString(&account{}, c.expr)
+		if err != nil {
+			t.Errorf("expected %s, but got error %v", c.expected, err)
+			continue
+		}
+		if got != c.expected {
+			t.Errorf("rendered %s, expected %s", got, c.expected)
+		}
+	}
+
+	invalid := []Expr{
+		Eq("Missing", 1),
+		ColumnValue("Email", hbase.TCompareOperator_NO_OP, "a"),
+		And(),
+		Or(KeyOnly(), Page(0)),
+		Raw(""),
+		RowRange("", ""),
+	}
+	for i, expr := range invalid {
+		if s, err := db.FilterString(&account{}, expr); err == nil {
+			t.Errorf("invalid filter %d rendered as %s", i, s)
+		}
+	}
+	if _, err := db.FilterString(&account{}, Eq("Missing", 1)); !errors.Is(err, ErrUnknownColumn) {
+		t.Errorf("expected ErrUnknownColumn, but got %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package horm
+
+import (
+	"context"
+	"testing"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/challenai/horm/codec"
+	"github.com/challenai/horm/thrift/hbase"
+)
+
+// fakeClient records the args of every call, answers the conditional writes with pass, gets, increments and appends with an empty row.
+// other results are left to answer, which is also called after the defaults so it can override them.
+type fakeClient struct {
+	pass   bool
+	calls  []string
+	args   []thrift.TStruct
+	answer func(ctx context.Context, method string, args, result thrift.TStruct) error
+}
+
+func (c *fakeClient) Call(ctx context.Context, method string, args, result thrift.TStruct) (thrift.ResponseMeta, error) {
+	c.calls = append(c.calls, method)
+	c.args = append(c.args, args)
+	switch r := result.(type) {
+	case *hbase.THBaseServiceCheckAndPutResult:
+		r.Success = &c.pass
+	case *hbase.THBaseServiceCheckAndMutateResult:
+		r.Success = &c.pass
+	case *hbase.THBaseServiceCheckAndDeleteResult:
+		r.Success = &c.pass
+	case *hbase.THBaseServiceGetResult:
+		r.Success = &hbase.TResult_{}
+	case *hbase.THBaseServiceAppendResult:
+		r.Success = &hbase.TResult_{}
+	case *hbase.THBaseServiceIncrementResult:
+		r.Success = &hbase.TResult_{}
+	}
+	if c.answer != nil {
+		return thrift.ResponseMeta{}, c.answer(ctx, method, args, result)
+	}
+	return thrift.ResponseMeta{}, nil
+}
+
+// the args of the last call, which should be method.
+func (c *fakeClient) last(t *testing.T, method string) thrift.TStruct {
+	t.Helper()
+	if len(c.calls) == 0 || c.calls[len(c.calls)-1] != method {
+		t.Fatalf("expected a %s call, but got %v", method, c.calls)
+	}
+	return c.args[len(c.args)-1]
+}
+
+func newFakeDB(pass bool) (*DB, *fakeClient) {
+	fc := &fakeClient{pass: pass}
+	return NewDB(hbase.NewTHBaseServiceClient(fc), &codec.DefaultCodec{}), fc
+}
+
+type account struct {
+	*Model
+	Email string `horm:"info,email"`
+	Age   int    `horm:"info,age"`
+}
+
+func (*account) Namespace() string { return "test" }
+func (*account) TableName() string { return "account" }
+
+type versionedAccount struct {
+	*Model
+	Email string `horm:"info,email"`
+	Ver   int64  `horm:"info,ver,version"`
+}
+
+func (*versionedAccount) Namespace() string { return "test" }
+func (*versionedAccount) TableName() string { return "account" }
+
+// value of family:qualifier in put, nil if it isn't written.
+func columnOf(put *hbase.TPut, family, qualifier string) []byte {
+	for _, v := range put.ColumnValues {
+		if string(v.Family) == family && string(v.Qualifier) == qualifier {
+			return v.Value
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package horm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/challenai/horm/thrift/hbase"
+)
+
+// Version is a value of a column written at Timestamp.
+type Version struct {
+	Timestamp time.Time
+	Value     interface{} // decoded with the codec of the field, the type is the field type
+}
+
+// History holds the versions of a row, read it with DB.History.
+type History struct {
+	Rowkey string
+	// Fields maps go field names to their versions, newest first
+	Fields map[string][]Version
+	// Timestamps are all the distinct write times of the row, oldest first
+	Timestamps []time.Time
+
+	schm      *schema
+	modelType reflect.Type
+}
+
+// read up to maxVersions versions of every column of a row, model should be a struct pointer like &User{}.
+// ErrRecordNotFound is returned if the row doesn't exist.
+func (h *DB) History(ctx context.Context, model interface{}, rowkey string, maxVersions int32) (*History, error) {
+	tb, err := tableOf(model)
+	if err != nil {
+		return nil, err
+	}
+	modelType := reflect.TypeOf(model).Elem()
+	schm, err := h.loadSchema(modelType)
+	if err != nil {
+		return nil, err
+	}
+	if maxVersions <= 0 {
+		return nil, fmt.Errorf("max versions should be positive, but got %d", maxVersions)
+	}
+
+	tx := h.session()
+	tGet := &hbase.TGet{
+		Row:         []byte(rowkey),
+		MaxVersions: &maxVersions,
+	}
+	h.newOptions(nil).applyGet(tGet)
+	result, err := tx.db.Get(ctx, []byte(fmt.Sprintf("%s:%s", tb.Namespace(), tb.TableName())), tGet)
+	if err != nil {
+		return nil, err
+	}
+	if result == nil || len(result.ColumnValues) == 0 {
+		return nil, ErrRecordNotFound
+	}
+
+	hs := &History{
+		Rowkey:    rowkey,
+		Fields:    map[string][]Version{},
+		schm:      schm,
+		modelType: modelType,
+	}
+	seen := map[int64]bool{}
+	for _, v := range result.ColumnValues {
+		f := schm.columns[string(v.Family)][string(v.Qualifier)]
+		if f == nil {
+			continue
+		}
+		decoded := reflect.New(f.typ).Elem()
+		if err = f.decode(decoded, v.Value); err != nil {
+			return nil, &DecodeError{
+				Rowkey:    rowkey,
+				Family:    string(v.Family),
+				Qualifier: string(v.Qualifier),
+				Field:     f.name,
+				Err:       err,
+			}
+		}
+		ts := v.GetTimestamp()
+		hs.Fields[f.name] = append(hs.Fields[f.name], Version{
+			Timestamp: time.UnixMilli(ts),
+			Value:     decoded.Interface(),
+		})
+		if !seen[ts] {
+			seen[ts] = true
+			hs.Timestamps = append(hs.Timestamps, time.UnixMilli(ts))
+		}
+	}
+	for _, versions := range hs.Fields {
+		sort.SliceStable(versions, func(i, j int) bool {
+			return versions[i].Timestamp.After(versions[j].Timestamp)
+		})
+	}
+	sort.Slice(hs.Timestamps, func(i, j int) bool {
+		return hs.Timestamps[i].Before(hs.Timestamps[j])
+	})
+	return hs, nil
+}
+
+// Snapshots rebuilds the model as it was at each of Timestamps into list, like *[]User,
+// so list[i] holds the latest version of every field written at or before Timestamps[i].
+func (hs *History) Snapshots(list interface{}) error {
+	modelType, err := listModelType(list)
+	if err != nil {
+		return err
+	}
+	if modelType != hs.modelType {
+		return fmt.Errorf("%w: history of %s can't be decoded into %T", ErrInvalidModel, hs.modelType, list)
+	}
+
+	listValue := reflect.ValueOf(list).Elem()
+	for _, ts := range hs.Timestamps {
+		m := reflect.New(modelType).Elem()
+		m.Field(hs.schm.model).Set(reflect.ValueOf(&Model{
+			Rowkey:    hs.Rowkey,
+			Timestamp: ts,
+		}))
+		for _, f := range hs.schm.fields {
+			// versions are newest first, pick the first one not after ts
+			for _, v := range hs.Fields[f.name] {
+				if !v.Timestamp.After(ts) {
+					m.Field(f.index).Set(reflect.ValueOf(v.Value))
+					break
+				}
+			}
+		}
+		listValue.Set(reflect.Append(listValue, m))
+	}
+	return nil
+}
@@ -1,12 +1,15 @@
 package horm
 
 import (
+	"bytes"
 	"context"
-	"errors"
 	"fmt"
 	"reflect"
-	"strings"
+	"sync"
+	"time"
 
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/challenai/horm/client"
 	"github.com/challenai/horm/codec"
 	"github.com/challenai/horm/thrift/hbase"
 )
@@ -18,19 +21,31 @@ const (
 	BatchResultSize int32  = 1 << 6 // todo: selft-customized batchResultSize, default set to be 64KB (assume 1KB bytes per row)
 )
 
-// DB represent a HBase database
+// DB represent a HBase database.
+//
+// DB is safe for concurrent use by multiple goroutines, so a single DB can be shared
+// by all the handlers of an HTTP server. a thrift transport runs one call at a time, so the calls
+// of a DB built on a single client are serialized, build it on client.NewHBaseClientPool
+// (or NewHBasePool) to run calls concurrently. every operation returns a new session carrying
+// its own Error and RowsAffected, read them from the returned *DB rather than the shared one:
+//
+//	if err := db.Get(ctx, user, rowkey).Error; err != nil {
+//		return err
+//	}
 type DB struct {
 	Error        error
 	RowsAffected int64
+	Stale        bool // whether any row read by the operation was served stale by a secondary replica
 	db           *hbase.THBaseServiceClient
-	schemas      map[string]schema
-	cdc          codec.Codec
+	opts         []Option // applied before the options of every call
+	*config
 }
 
-// schema used to store struct field and column mapping information
-type schema struct {
-	col2field map[string]int
-	field2col []string
+// config is shared by a DB and all the sessions derived from it.
+type config struct {
+	client  thrift.TClient
+	schemas sync.Map // map[reflect.Type]*schema
+	cdc     codec.Codec
 }
 
 // filter input raw filter string and rows limit to thrift server
@@ -39,97 +54,94 @@ type Filter struct {
 	Limit        int32
 }
 
-// create a new hbase database from thrift client
-func NewDB(client *hbase.THBaseServiceClient, c codec.Codec) *DB {
+// create a new hbase database from thrift client,
+// calls on the thrift client are serialized because thrift transports are not goroutine-safe,
+// unless it's a client.Pool.
+func NewDB(tc *hbase.THBaseServiceClient, c codec.Codec) *DB {
+	tClient := tc.Client_()
+	if _, ok := tClient.(*client.Pool); !ok {
+		tClient = client.NewSyncClient(tClient)
+	}
 	hb := &DB{
-		db:      client,
-		schemas: map[string]schema{},
-		cdc:     c,
+		config: &config{
+			client: tClient,
+			cdc:    c,
+		},
 	}
-	return hb
+	return hb.session()
 }
 
-// HBase rows range query
-func (h *DB) Find(ctx context.Context, list interface{}, startRow, stopRow string, selects []Column, filter *Filter) *DB {
-	// border case: input a nil as model, not allowed
-	if list == nil {
-		panic("can't input nil as a model")
-	}
-	if reflect.TypeOf(list).Kind() != reflect.Ptr {
-		panic("list should be a slice of struct pointer, for example: *[]User")
-	}
-	if reflect.TypeOf(list).Elem().Kind() != reflect.Slice {
-		panic("list should be a slice of struct pointer, for example: *[]User")
-	}
-	modelType := reflect.TypeOf(list).Elem().Elem()
-	if modelType.Kind() != reflect.Struct {
-		panic("list should be a slice of struct pointer, for example: *[]User")
+// create a new session sharing the same transport, codec and schemas,
+// the generated service client keeps per-call state so every session owns one.
+func (h *DB) session() *DB {
+	return &DB{
+		db:     hbase.NewTHBaseServiceClient(h.client),
+		opts:   h.opts,
+		config: h.config,
 	}
-	model := reflect.New(modelType).Interface()
-	tb, ok := model.(Table)
-	if !ok {
-		panic("please set namespace and table name for this model")
+}
+
+// With returns a DB sharing the same transport and schemas, all of whose operations apply opts,
+// the options passed to an operation are applied after them:
+//
+//	logs := db.With(horm.WithDurability(hbase.TDurability_ASYNC_WAL), horm.WithTTL(24*time.Hour))
+func (h *DB) With(opts ...Option) *DB {
+	tx := h.session()
+	// cap the slice so DBs derived from the same one don't share appended options
+	tx.opts = append(h.opts[:len(h.opts):len(h.opts)], opts...)
+	return tx
+}
+
+// HBase rows range query
+func (h *DB) Find(ctx context.Context, list interface{}, startRow, stopRow string, selects []Column, filter *Filter, opts ...Option) *DB {
+	tx := h.session()
+	modelType, err := listModelType(list)
+	if err != nil {
+		tx.Error = err
+		return tx
 	}
 
-	tScan := &hbase.TScan{
-		StartRow: []byte(startRow),
-		StopRow:  []byte(stopRow),
+	rows, err := h.Rows(ctx, reflect.New(modelType).Interface(), startRow, stopRow, selects, filter, opts...)
+	if err != nil {
+		tx.Error = err
+		return tx
 	}
-	if selects != nil && len(selects) > 0 {
-		tScan.Columns = make([]*hbase.TColumn, 0, len(selects))
-		for _, v := range selects {
-			col := &hbase.TColumn{
-				Family:    []byte(v.Family),
-				Qualifier: []byte(v.Name),
-			}
-			if v.Timestamp != 0 {
-				ts := int64(v.Timestamp)
-				col.Timestamp = &ts
-			}
-			tScan.Columns = append(tScan.Columns, col)
+	tx.Error = scanAll(rows, list)
+	tx.Stale = rows.stale
+	return tx
+}
+
+// decode all the rows into list and close them, list should be a slice pointer like *[]User.
+func scanAll(rows *Rows, list interface{}) error {
+	defer rows.Close()
+
+	listValue := reflect.ValueOf(list).Elem()
+	modelType := listValue.Type().Elem()
+	for rows.Next() {
+		m := reflect.New(modelType)
+		if err := rows.Scan(m.Interface()); err != nil {
+			return err
 		}
+		listValue.Set(reflect.Append(listValue, m.Elem()))
+	}
+	if err := rows.Err(); err != nil {
+		return err
 	}
+	return rows.Close()
+}
+
+// build a thrift scan from rows range, picked columns and filter.
+func buildScan(startRow, stopRow string, selects []Column, filter *Filter) *hbase.TScan {
+	tScan := hbase.NewTScan()
+	tScan.StartRow = []byte(startRow)
+	tScan.StopRow = []byte(stopRow)
+	tScan.Columns = buildColumns(selects)
 	if filter != nil {
 		if filter.FilterString != "" {
 			tScan.FilterString = []byte(filter.FilterString)
 		}
 	}
-
-	var scanResults []*hbase.TResult_
-	resultSz := BatchResultSize
-	listValue := reflect.ValueOf(list).Elem()
-	for {
-		var lastResult *hbase.TResult_ = nil
-		// get query size in this batch
-		if filter != nil {
-			resultSz = getQuerySize(BatchResultSize, filter.Limit-int32(len(scanResults)))
-			if resultSz == 0 {
-				break
-			}
-		}
-		currentResults, err := h.db.GetScannerResults(ctx, []byte(fmt.Sprintf("%s:%s", tb.Namespace(), tb.TableName())), tScan, resultSz)
-		if err != nil {
-			h.Error = err
-			return h
-		}
-		for _, tResult := range currentResults {
-			lastResult = tResult
-			scanResults = append(scanResults, tResult)
-		}
-		if lastResult == nil {
-			break
-		} else {
-			nextStartRow := getClosestRowAfter(lastResult.Row)
-			tScan.StartRow = nextStartRow
-		}
-	}
-	for _, v := range scanResults {
-		m := reflect.New(modelType)
-		mPtr := m.Elem()
-		h.retrieveValue(&mPtr, v)
-		listValue.Set(reflect.Append(listValue, m.Elem()))
-	}
-	return h
+	return tScan
 }
 
 func getQuerySize(batchSz, diff int32) int32 {
@@ -142,233 +154,529 @@ func getQuerySize(batchSz, diff int32) int32 {
 	return diff
 }
 
-func getClosestRowAfter(row []byte) []byte {
-	var nextRow []byte
-	var i int
-	for i = 0; i < len(row); i++ {
-		nextRow = append(nextRow, row[i])
-	}
-	nextRow = append(nextRow, 0x00)
-	return nextRow
-}
-
-func (h *DB) retrieveValue(value *reflect.Value, result *hbase.TResult_) {
-	schm, ok := h.schemas[value.Type().Name()]
-	if !ok {
-		schm = h.registerModel(*value)
+func (h *DB) retrieveValue(value *reflect.Value, result *hbase.TResult_) error {
+	schm, err := h.loadSchema(value.Type())
+	if err != nil {
+		return err
 	}
 	base := &Model{
 		Rowkey: string(result.Row),
+		Stale:  result.Stale,
 	}
-	value.FieldByName(ModelName).Set(reflect.ValueOf(base))
-	for _, v := range result.ColumnValues {
-		key := fmt.Sprintf("%s:%s", v.Family, v.Qualifier)
-		if idx, ok := schm.col2field[key]; ok {
-			field := value.Field(idx)
-			switch field.Kind() {
-			case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-				n, err := h.cdc.DecodeInt(v.GetValue())
-				if err != nil {
-					fmt.Println("failed to parse int column")
-					panic(err)
-				}
-				field.SetInt(n)
-			case reflect.Float32, reflect.Float64:
-				n, err := h.cdc.DecodeFloat(v.GetValue())
-				if err != nil {
-					fmt.Println("failed to parse float column")
-					panic(err)
-				}
-				field.SetFloat(n)
-			case reflect.String:
-				s, err := h.cdc.DecodeString(v.GetValue())
-				if err != nil {
-					fmt.Println("failed to parse string column")
-					panic(err)
-				}
-				field.SetString(s)
-			case reflect.Bool:
-				b, err := h.cdc.DecodeBool(v.GetValue())
-				if err != nil {
-					fmt.Println("failed to parse bool column")
-					panic(err)
-				}
-				field.SetBool(b)
-			case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-				n, err := h.cdc.DecodeUint(v.GetValue())
-				if err != nil {
-					fmt.Println("failed to parse uint column")
-					panic(err)
-				}
-				field.SetUint(n)
-			}
-		}
+	if schm.stamps {
+		base.FieldTimestamps = make(map[string]time.Time, len(schm.fields))
 	}
-}
-
-// parse imported model so that we don't need to parse all the model fields everytime.
-func (h *DB) registerModel(values reflect.Value) schema {
-	schm := schema{}
-	schm.field2col = make([]string, values.NumField())
-	schm.col2field = map[string]int{}
-	for i := 0; i < values.Type().NumField(); i++ {
-		if values.Type().Field(i).Name == "Model" {
+	value.Field(schm.model).Set(reflect.ValueOf(base))
+	var latest int64
+	var last *field
+	for _, v := range result.ColumnValues {
+		// indexing with converted bytes doesn't allocate
+		f := schm.columns[string(v.Family)][string(v.Qualifier)]
+		// versions of a column come newest first, only keep the newest one
+		if f == nil || f == last {
 			continue
 		}
-		tagsStr := values.Type().Field(i).Tag.Get(HBaseTagHint)
-		if tagsStr == "" || tagsStr == "-" {
-			continue
+		last = f
+		if v.Timestamp != nil {
+			if *v.Timestamp > latest {
+				latest = *v.Timestamp
+			}
+			if schm.stamps {
+				base.FieldTimestamps[f.name] = time.UnixMilli(*v.Timestamp)
+			}
 		}
-		tagsList := strings.Split(tagsStr, ",")
-		if len(tagsList) < 2 {
-			panic("hbase column doesn't have column family or qualifier")
+		if err = f.decode(value.Field(f.index), v.Value); err != nil {
+			return &DecodeError{
+				Rowkey:    string(result.Row),
+				Family:    string(v.Family),
+				Qualifier: string(v.Qualifier),
+				Field:     f.name,
+				Err:       err,
+			}
 		}
-		name := strings.Join(tagsList[:2], ":")
-		schm.field2col[i] = name
-		schm.col2field[name] = i
 	}
-	h.schemas[values.Type().Name()] = schm
-	return schm
+	if latest > 0 {
+		base.Timestamp = time.UnixMilli(latest)
+	}
+	return nil
 }
 
 // get a single row.
-func (h *DB) Get(ctx context.Context, model interface{}, rowkey string) *DB {
-	// border case: input a nil as model, not allowed
-	if model == nil {
-		panic("can't input nil as a model")
-	}
-	tb, ok := model.(Table)
-	if !ok {
-		panic("please set namespace and table name for this model")
+func (h *DB) Get(ctx context.Context, model interface{}, rowkey string, opts ...Option) *DB {
+	tx := h.session()
+	tb, err := tableOf(model)
+	if err != nil {
+		tx.Error = err
+		return tx
 	}
 
-	result, err := h.db.Get(ctx, []byte(fmt.Sprintf("%s:%s", tb.Namespace(), tb.TableName())), &hbase.TGet{Row: []byte(rowkey)})
+	tGet := &hbase.TGet{Row: []byte(rowkey)}
+	h.newOptions(opts).applyGet(tGet)
+	result, err := tx.db.Get(ctx, []byte(fmt.Sprintf("%s:%s", tb.Namespace(), tb.TableName())), tGet)
 	if err != nil {
-		h.Error = err
-		return h
+		tx.Error = err
+		return tx
+	}
+	// HBase returns an empty result rather than an error for a missing row
+	if result == nil || len(result.ColumnValues) == 0 {
+		tx.Error = ErrRecordNotFound
+		return tx
 	}
 
 	value := reflect.ValueOf(model).Elem()
-	h.retrieveValue(&value, result)
+	tx.Error = h.retrieveValue(&value, result)
+	tx.Stale = result.Stale
 
-	return h
+	return tx
 }
 
-// insert or update model to HBase
-func (h *DB) Set(ctx context.Context, model interface{}, selects []Column) *DB {
-	// border case: input a nil as model, not allowed
-	if model == nil {
-		panic("can't input nil as a model")
+// check whether a row exists without transferring its cells.
+func (h *DB) Exists(ctx context.Context, model interface{}, rowkey string, opts ...Option) (bool, error) {
+	tx := h.session()
+	tb, err := tableOf(model)
+	if err != nil {
+		return false, err
 	}
-	tb, ok := model.(Table)
-	if !ok {
-		panic("please set namespace and table name for this model")
+
+	tGet := &hbase.TGet{Row: []byte(rowkey)}
+	h.newOptions(opts).applyGet(tGet)
+	return tx.db.Exists(ctx, []byte(fmt.Sprintf("%s:%s", tb.Namespace(), tb.TableName())), tGet)
+}
+
+// check whether each of the rows exists, the result keeps the order of rowkeys.
+func (h *DB) ExistsAll(ctx context.Context, model interface{}, rowkeys []string, opts ...Option) ([]bool, error) {
+	tx := h.session()
+	tb, err := tableOf(model)
+	if err != nil {
+		return nil, err
+	}
+	if len(rowkeys) == 0 {
+		return []bool{}, nil
+	}
+
+	o := h.newOptions(opts)
+	tGets := make([]*hbase.TGet, 0, len(rowkeys))
+	for _, rowkey := range rowkeys {
+		tGet := &hbase.TGet{Row: []byte(rowkey)}
+		o.applyGet(tGet)
+		tGets = append(tGets, tGet)
+	}
+	return tx.db.ExistsAll(ctx, []byte(fmt.Sprintf("%s:%s", tb.Namespace(), tb.TableName())), tGets)
+}
+
+// get multiple rows by rowkeys into a slice of models, like *[]User.
+// found rows keep the order of rowkeys, rowkeys not found are reported by a *MissingRowsError.
+func (h *DB) GetMany(ctx context.Context, list interface{}, rowkeys []string, opts ...Option) *DB {
+	tx := h.session()
+	modelType, err := listModelType(list)
+	if err != nil {
+		tx.Error = err
+		return tx
+	}
+	tb, err := tableOf(reflect.New(modelType).Interface())
+	if err != nil {
+		tx.Error = err
+		return tx
+	}
+
+	tx.Error = nil
+	tx.RowsAffected = 0
+	if len(rowkeys) == 0 {
+		return tx
+	}
+	o := h.newOptions(opts)
+	tGets := make([]*hbase.TGet, 0, len(rowkeys))
+	for _, rowkey := range rowkeys {
+		tGet := &hbase.TGet{Row: []byte(rowkey)}
+		o.applyGet(tGet)
+		tGets = append(tGets, tGet)
+	}
+	results, err := tx.db.GetMultiple(ctx, []byte(fmt.Sprintf("%s:%s", tb.Namespace(), tb.TableName())), tGets)
+	if err != nil {
+		tx.Error = err
+		return tx
+	}
+
+	listValue := reflect.ValueOf(list).Elem()
+	var missing []string
+	for i, rowkey := range rowkeys {
+		// results are returned in the same order as the gets
+		if i >= len(results) || results[i] == nil || len(results[i].ColumnValues) == 0 {
+			missing = append(missing, rowkey)
+			continue
+		}
+		m := reflect.New(modelType).Elem()
+		if err = h.retrieveValue(&m, results[i]); err != nil {
+			tx.Error = err
+			return tx
+		}
+		if results[i].Stale {
+			tx.Stale = true
+		}
+		listValue.Set(reflect.Append(listValue, m))
+		tx.RowsAffected++
+	}
+	if len(missing) > 0 {
+		tx.Error = &MissingRowsError{Rowkeys: missing}
+	}
+	return tx
+}
+
+// insert or update model to HBase.
+// a model with a version field is only written if the row still holds the version read before,
+// the version of model is bumped on success, otherwise ErrStaleObject is returned.
+func (h *DB) Set(ctx context.Context, model interface{}, selects []Column, opts ...Option) *DB {
+	tx := h.session()
+	tb, err := tableOf(model)
+	if err != nil {
+		tx.Error = err
+		return tx
 	}
 
 	value := reflect.ValueOf(model).Elem()
 	put := &hbase.TPut{}
-	h.injectValue(&value, put, selects)
-	err := h.db.Put(ctx, []byte(fmt.Sprintf("%s:%s", tb.Namespace(), tb.TableName())), put)
-	h.Error = err
-	return h
+	if err = h.injectValue(&value, put, selects); err != nil {
+		tx.Error = err
+		return tx
+	}
+	schm, err := h.loadSchema(value.Type())
+	if err != nil {
+		tx.Error = err
+		return tx
+	}
+	h.newOptions(opts).applyPut(put, value)
+	table := []byte(fmt.Sprintf("%s:%s", tb.Namespace(), tb.TableName()))
+	if schm.version != nil {
+		err = tx.putVersioned(ctx, table, schm, value, put)
+	} else if puts := schm.splitPut(put); len(puts) == 1 {
+		err = tx.db.Put(ctx, table, put)
+	} else {
+		err = tx.db.MutateRow(ctx, table, putMutations(put.Row, puts))
+	}
+	tx.Error = err
+	if err == nil {
+		tx.RowsAffected = 1
+	}
+	return tx
 }
 
-func (h *DB) injectValue(value *reflect.Value, put *hbase.TPut, selects []Column) {
-	if put == nil {
-		return
+// put a versioned model only if its version column still holds the version of model, then bump it.
+func (h *DB) putVersioned(ctx context.Context, table []byte, schm *schema, value reflect.Value, put *hbase.TPut) error {
+	f := schm.version
+	current := value.Field(f.index)
+	var expected []byte
+	// a zero version means the model has never been written, so the column should be absent
+	if !current.IsZero() {
+		expected = f.encode(current)
+	}
+	next := nextVersion(f, value, put)
+	if puts := schm.splitPut(put); len(puts) > 1 {
+		if err := h.checkVersionAndMutate(ctx, table, schm, value, putMutations(put.Row, puts)); err != nil {
+			return err
+		}
+		current.Set(next)
+		return nil
+	}
+	ok, err := h.db.CheckAndPut(ctx, table, put.Row, f.family, f.qualifier, expected, put)
+	if err != nil {
+		return err
 	}
-	schm, ok := h.schemas[value.Type().Name()]
 	if !ok {
-		schm = h.registerModel(*value)
+		return ErrStaleObject
 	}
+	current.Set(next)
+	return nil
+}
+
+// write the next version of model into put and return it, the caller sets it back once the put succeeds.
+func nextVersion(f *field, value reflect.Value, put *hbase.TPut) reflect.Value {
+	current := value.Field(f.index)
+	next := reflect.New(f.typ).Elem()
+	switch next.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		next.SetInt(current.Int() + 1)
+	default:
+		next.SetUint(current.Uint() + 1)
+	}
+	// the version column is always written, even if it's not picked
+	putColumn(put, &hbase.TColumnValue{
+		Family:    f.family,
+		Qualifier: f.qualifier,
+		Value:     f.encode(next),
+	})
+	return next
+}
+
+// add a column value to put, replace the value if the column is already there.
+func putColumn(put *hbase.TPut, col *hbase.TColumnValue) {
+	for i, v := range put.ColumnValues {
+		if bytes.Equal(v.Family, col.Family) && bytes.Equal(v.Qualifier, col.Qualifier) {
+			put.ColumnValues[i] = col
+			return
+		}
+	}
+	put.ColumnValues = append(put.ColumnValues, col)
+}
 
-	// todo: assert horm.Model is a pointer when verify basic model extend
-	// fmt.Println(value.FieldByName(ModelName).Elem().FieldByName(RowName).String()))
-	put.Row = []byte(value.FieldByName(ModelName).Elem().FieldByName(RowName).String())
+func (h *DB) injectValue(value *reflect.Value, put *hbase.TPut, selects []Column) error {
+	if put == nil {
+		return nil
+	}
+	schm, err := h.loadSchema(value.Type())
+	if err != nil {
+		return err
+	}
+
+	rowkey, err := rowkeyOf(*value)
+	if err != nil {
+		return err
+	}
+	put.Row = []byte(rowkey)
 	put.ColumnValues = []*hbase.TColumnValue{}
 
 	if selects != nil && len(selects) > 0 {
 		for _, v := range selects {
-			field := value.Field(schm.col2field[fmt.Sprintf("%s:%s", v.Family, v.Name)])
-			col := &hbase.TColumnValue{}
-			h.buildColumn(v.Family, v.Name, &field, col)
-			put.ColumnValues = append(put.ColumnValues, col)
+			f := schm.column(v.Family, v.Name)
+			if f == nil {
+				return fmt.Errorf("%w: %s:%s is not mapped by %s", ErrUnknownColumn, v.Family, v.Name, value.Type())
+			}
+			put.ColumnValues = append(put.ColumnValues, f.columnValue(*value))
 		}
 	} else {
-		for i, v := range schm.field2col {
-			if v != "" {
-				descriptors := strings.Split(v, ":")
-				if len(descriptors) == 2 {
-					field := value.Field(i)
-					col := &hbase.TColumnValue{}
-					h.buildColumn(descriptors[0], descriptors[1], &field, col)
-					put.ColumnValues = append(put.ColumnValues, col)
-				}
-			}
+		put.ColumnValues = make([]*hbase.TColumnValue, 0, len(schm.fields))
+		for _, f := range schm.fields {
+			put.ColumnValues = append(put.ColumnValues, f.columnValue(*value))
 		}
 	}
+	return nil
 }
 
-func (h *DB) buildColumn(family, qualifier string, field *reflect.Value, columnValue *hbase.TColumnValue) {
-	columnValue.Family = []byte(family)
-	columnValue.Qualifier = []byte(qualifier)
-	switch field.Kind() {
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		columnValue.Value = h.cdc.EncodeInt(field.Int())
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		columnValue.Value = h.cdc.EncodeUint(field.Uint())
-	case reflect.Float32, reflect.Float64:
-		columnValue.Value = h.cdc.EncodeFloat(field.Float())
-	case reflect.String:
-		columnValue.Value = h.cdc.EncodeString(field.String())
-	case reflect.Bool:
-		columnValue.Value = h.cdc.EncodeBool(field.Bool())
+// check rows is a slice of structs or struct pointers and return the struct type, like User for []*User.
+func validateListable(t reflect.Type) (reflect.Type, error) {
+	if t == nil {
+		return nil, fmt.Errorf("%w: can't input nil as rows", ErrInvalidModel)
+	}
+	if t.Kind() == reflect.Slice || t.Kind() == reflect.Array {
+		elem := t.Elem()
+		if elem.Kind() == reflect.Ptr {
+			elem = elem.Elem()
+		}
+		if elem.Kind() == reflect.Struct {
+			return elem, nil
+		}
 	}
+	return nil, fmt.Errorf("%w: invalid row type, should be a slice like []User or []*User but got %s", ErrInvalidModel, t)
 }
 
-func validateListable(t reflect.Type) bool {
-	switch t.Kind() {
-	case reflect.Slice:
-		fallthrough
-	case reflect.Array:
-		return true
+// get the i-th model of rows validated by validateListable, pointers are dereferenced.
+func rowAt(rows reflect.Value, i int) (reflect.Value, error) {
+	row := rows.Index(i)
+	if row.Kind() != reflect.Ptr {
+		return row, nil
+	}
+	if row.IsNil() {
+		return reflect.Value{}, fmt.Errorf("%w: row %d of %s is nil", ErrInvalidModel, i, rows.Type())
 	}
-	err := fmt.Errorf("invalid row type to report, should be a slice but got %s", t)
-	panic(err)
-	// return false
+	return row.Elem(), nil
 }
 
-func (h *DB) BatchSet(ctx context.Context, rows interface{}, selects []Column) *DB {
-	if !validateListable(reflect.TypeOf(rows)) {
-		h.Error = errors.New("batchSet need a slice as input, like []User")
-		return h
+func (h *DB) BatchSet(ctx context.Context, rows interface{}, selects []Column, opts ...Option) *DB {
+	tx := h.session()
+	modelType, err := validateListable(reflect.TypeOf(rows))
+	if err != nil {
+		tx.Error = err
+		return tx
 	}
 	v := reflect.ValueOf(rows)
+	var schm *schema
+	if v.Len() > 0 {
+		// versions can't be checked by a multiple put
+		schm, err = h.loadSchema(modelType)
+		if err != nil {
+			tx.Error = err
+			return tx
+		}
+		if schm.version != nil {
+			tx.Error = fmt.Errorf("%w: %s has a version field, write it with Set", ErrInvalidModel, modelType)
+			return tx
+		}
+	}
+	o := h.newOptions(opts)
 	puts := []*hbase.TPut{}
 	for i := 0; i < v.Len(); i++ {
-		field := v.Index(i)
-		fmt.Println(field)
+		field, err := rowAt(v, i)
+		if err != nil {
+			tx.Error = err
+			return tx
+		}
 		put := &hbase.TPut{}
-		h.injectValue(&field, put, selects)
-		puts = append(puts, put)
+		if err := h.injectValue(&field, put, selects); err != nil {
+			tx.Error = err
+			return tx
+		}
+		o.applyPut(put, field)
+		puts = append(puts, schm.splitPut(put)...)
 	}
+	tx.Error = nil
 	if v.Len() > 0 {
-		modelType := v.Index(0).Type()
-		m := reflect.New(modelType)
-		tb, ok := m.Interface().(Table)
-		if !ok {
-			panic("please set namespace and table name for this model")
+		tb, err := tableOf(reflect.New(modelType).Interface())
+		if err != nil {
+			tx.Error = err
+			return tx
+		}
+		err = tx.db.PutMultiple(ctx, []byte(fmt.Sprintf("%s:%s", tb.Namespace(), tb.TableName())), puts)
+		tx.Error = err
+		if err == nil {
+			tx.RowsAffected = int64(v.Len())
 		}
-		err := h.db.PutMultiple(ctx, []byte(fmt.Sprintf("%s:%s", tb.Namespace(), tb.TableName())), puts)
-		h.Error = err
 	}
-	return h
+	return tx
 }
 
-func (h *DB) Delete(ctx context.Context, model interface{}, rowkey string) *DB {
-	return h
+// delete a single row, or only the selected columns of it.
+// a Column without Name deletes the whole family, the other columns lose all their versions,
+// or all the versions at or before the Timestamp of the Column.
+// pass WithDeleteType(hbase.TDeleteType_DELETE_COLUMN) to delete the latest or the timestamped version only.
+func (h *DB) Delete(ctx context.Context, model interface{}, rowkey string, selects []Column, opts ...Option) *DB {
+	tx := h.session()
+	tb, err := tableOf(model)
+	if err != nil {
+		tx.Error = err
+		return tx
+	}
+
+	del := &hbase.TDelete{
+		Row:     []byte(rowkey),
+		Columns: buildColumns(selects),
+	}
+	h.newOptions(opts).applyDelete(del)
+	err = tx.db.DeleteSingle(ctx, []byte(fmt.Sprintf("%s:%s", tb.Namespace(), tb.TableName())), del)
+	tx.Error = err
+	tx.RowsAffected = 0
+	if err == nil {
+		tx.RowsAffected = 1
+	}
+	return tx
+}
+
+// delete a slice of models by their rowkeys, like []User or []*User.
+// rows which the server failed to delete are reported by a *DeleteError.
+func (h *DB) DeleteAll(ctx context.Context, rows interface{}, selects []Column, opts ...Option) *DB {
+	tx := h.session()
+	modelType, err := validateListable(reflect.TypeOf(rows))
+	if err != nil {
+		tx.Error = err
+		return tx
+	}
+	tx.Error = nil
+	tx.RowsAffected = 0
+	v := reflect.ValueOf(rows)
+	if v.Len() == 0 {
+		return tx
+	}
+	o := h.newOptions(opts)
+	columns := buildColumns(selects)
+	deletes := make([]*hbase.TDelete, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		row, err := rowAt(v, i)
+		if err != nil {
+			tx.Error = err
+			return tx
+		}
+		rowkey, err := rowkeyOf(row)
+		if err != nil {
+			tx.Error = err
+			return tx
+		}
+		del := &hbase.TDelete{
+			Row:     []byte(rowkey),
+			Columns: columns,
+		}
+		o.applyDelete(del)
+		deletes = append(deletes, del)
+	}
+
+	tb, err := tableOf(reflect.New(modelType).Interface())
+	if err != nil {
+		tx.Error = err
+		return tx
+	}
+	failed, err := tx.db.DeleteMultiple(ctx, []byte(fmt.Sprintf("%s:%s", tb.Namespace(), tb.TableName())), deletes)
+	if err != nil {
+		tx.Error = err
+		return tx
+	}
+	tx.RowsAffected = int64(len(deletes) - len(failed))
+	if len(failed) > 0 {
+		delErr := &DeleteError{Rowkeys: make([]string, 0, len(failed))}
+		for _, d := range failed {
+			delErr.Rowkeys = append(delErr.Rowkeys, string(d.Row))
+		}
+		tx.Error = delErr
+	}
+	return tx
 }
 
-func (h *DB) DeleteAll(ctx context.Context, model interface{}) *DB {
-	return h
+// convert picked columns to thrift columns, return nil if no column picked.
+func buildColumns(selects []Column) []*hbase.TColumn {
+	if len(selects) == 0 {
+		return nil
+	}
+	columns := make([]*hbase.TColumn, 0, len(selects))
+	for _, v := range selects {
+		col := &hbase.TColumn{
+			Family: []byte(v.Family),
+		}
+		if v.Name != "" {
+			col.Qualifier = []byte(v.Name)
+		}
+		if v.Timestamp != 0 {
+			ts := v.Timestamp
+			col.Timestamp = &ts
+		}
+		columns = append(columns, col)
+	}
+	return columns
+}
+
+// get the namespace and table name of a model, model should be a struct pointer like *User.
+func tableOf(model interface{}) (Table, error) {
+	// border case: input a nil as model, not allowed
+	if model == nil {
+		return nil, fmt.Errorf("%w: can't input nil as a model", ErrInvalidModel)
+	}
+	t := reflect.TypeOf(model)
+	if t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Struct || reflect.ValueOf(model).IsNil() {
+		return nil, fmt.Errorf("%w: model should be a non-nil struct pointer like *User, but got %s", ErrInvalidModel, t)
+	}
+	tb, ok := model.(Table)
+	if !ok {
+		return nil, fmt.Errorf("%w: please set namespace and table name for %s", ErrInvalidModel, t)
+	}
+	return tb, nil
+}
+
+// get the model type of a list, list should be a slice pointer like *[]User.
+func listModelType(list interface{}) (reflect.Type, error) {
+	// border case: input a nil as model, not allowed
+	if list == nil {
+		return nil, fmt.Errorf("%w: can't input nil as a model", ErrInvalidModel)
+	}
+	t := reflect.TypeOf(list)
+	if t.Kind() != reflect.Ptr || t.Elem().Kind() != reflect.Slice || t.Elem().Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: list should be a slice of struct pointer, for example: *[]User, but got %s", ErrInvalidModel, t)
+	}
+	return t.Elem().Elem(), nil
+}
+
+// get the rowkey stored in the embedded horm.Model.
+func rowkeyOf(value reflect.Value) (string, error) {
+	base := value.FieldByName(ModelName)
+	if !base.IsValid() || base.Kind() != reflect.Ptr {
+		return "", fmt.Errorf("%w: %s should embed *horm.Model", ErrInvalidModel, value.Type())
+	}
+	if base.IsNil() {
+		return "", fmt.Errorf("%w: rowkey of %s is required, but horm.Model is nil", ErrInvalidModel, value.Type())
+	}
+	return base.Elem().FieldByName(RowName).String(), nil
 }
@@ -0,0 +1,265 @@
+package horm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/challenai/horm/client"
+	"github.com/challenai/horm/codec"
+	"github.com/challenai/horm/thrift/hbase"
+)
+
+func TestBatchSetAndDeleteAllRows(t *testing.T) {
+	db, fc := newFakeDB(true)
+	ctx := context.Background()
+	rows := []*account{{Model: &Model{Rowkey: "a"}}, {Model: &Model{Rowkey: "b"}}}
+	tx := db.BatchSet(ctx, rows, nil)
+	if tx.Error != nil {
+		t.Fatal(tx.Error)
+	}
+	if tx.RowsAffected != 2 {
+		t.Errorf("BatchSet affected %d rows, expected 2", tx.RowsAffected)
+	}
+	if puts := fc.last(t, "putMultiple").(*hbase.THBaseServicePutMultipleArgs).Tputs; len(puts) != 2 || string(puts[1].Row) != "b" {
+		t.Errorf("unexpected puts %v", puts)
+	}
+	if err := db.DeleteAll(ctx, rows, nil).Error; err != nil {
+		t.Fatal(err)
+	}
+	if dels := fc.last(t, "deleteMultiple").(*hbase.THBaseServiceDeleteMultipleArgs).Tdeletes; len(dels) != 2 || string(dels[0].Row) != "a" {
+		t.Errorf("unexpected deletes %v", dels)
+	}
+
+	for _, invalid := range []interface{}{[]int{1}, []*account{nil}, []interface{}{&account{}}, nil} {
+		if err := db.BatchSet(ctx, invalid, nil).Error; !errors.Is(err, ErrInvalidModel) {
+			t.Errorf("BatchSet(%T) returned %v, expected ErrInvalidModel", invalid, err)
+		}
+		if err := db.DeleteAll(ctx, invalid, nil).Error; !errors.Is(err, ErrInvalidModel) {
+			t.Errorf("DeleteAll(%T) returned %v, expected ErrInvalidModel", invalid, err)
+		}
+	}
+}
+
+type unexportedField struct {
+	*Model
+	name string `horm:"info,name"`
+}
+
+func (*unexportedField) Namespace() string { return "test" }
+func (*unexportedField) TableName() string { return "account" }
+
+func TestUnexportedFieldIsBadTag(t *testing.T) {
+	db, _ := newFakeDB(true)
+	m := &unexportedField{Model: &Model{Rowkey: "a"}, name: "a"}
+	if err := db.Set(context.Background(), m, nil).Error; !errors.Is(err, ErrBadTag) {
+		t.Errorf("expected ErrBadTag, but got %v", err)
+	}
+}
+
+// blockingClient holds every call until release is closed.
+type blockingClient struct {
+	started chan<- struct{}
+	release <-chan struct{}
+}
+
+func (c *blockingClient) Call(ctx context.Context, method string, args, result thrift.TStruct) (thrift.ResponseMeta, error) {
+	c.started <- struct{}{}
+	<-c.release
+	result.(*hbase.THBaseServiceExistsResult).Success = new(bool)
+	return thrift.ResponseMeta{}, nil
+}
+
+func TestPoolRunsCallsConcurrently(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	pool := client.NewPool(&blockingClient{started, release}, &blockingClient{started, release})
+	db := NewDB(hbase.NewTHBaseServiceClient(pool), &codec.DefaultCodec{})
+	table, get := []byte("test:account"), &hbase.TGet{Row: []byte("a")}
+
+	done := make(chan error)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := db.session().db.Exists(context.Background(), table, get)
+			done <- err
+		}()
+	}
+	// both calls are in flight at the same time
+	for i := 0; i < 2; i++ {
+		select {
+		case <-started:
+		case <-time.After(time.Second):
+			t.Fatal("calls on a pool of 2 clients ran one at a time")
+		}
+	}
+
+	// a third call waits for an idle client until its context is done
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := db.session().db.Exists(ctx, table, get); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected DeadlineExceeded from a busy pool, but got %v", err)
+	}
+
+	close(release)
+	for i := 0; i < 2; i++ {
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestDeleteRemovesAllVersionsByDefault(t *testing.T) {
+	db, fc := newFakeDB(true)
+	ctx := context.Background()
+	selects := []Column{{Family: "info", Name: "email"}}
+	if err := db.Delete(ctx, &account{}, "a", selects).Error; err != nil {
+		t.Fatal(err)
+	}
+	if del := fc.last(t, "deleteSingle").(*hbase.THBaseServiceDeleteSingleArgs).Tdelete; del.DeleteType != hbase.TDeleteType_DELETE_COLUMNS {
+		t.Errorf("delete type %s, expected DELETE_COLUMNS", del.DeleteType)
+	}
+	rows := []account{{Model: &Model{Rowkey: "a"}}}
+	if err := db.DeleteAll(ctx, rows, selects, WithDeleteType(hbase.TDeleteType_DELETE_COLUMN)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if del := fc.last(t, "deleteMultiple").(*hbase.THBaseServiceDeleteMultipleArgs).Tdeletes[0]; del.DeleteType != hbase.TDeleteType_DELETE_COLUMN {
+		t.Errorf("delete type %s, expected DELETE_COLUMN", del.DeleteType)
+	}
+}
+
+type audit struct {
+	*Model
+	Log string `horm:"info,log"`
+}
+
+func (*audit) Namespace() string { return "test" }
+func (*audit) TableName() string { return "audit" }
+
+func TestAppendTakesOptions(t *testing.T) {
+	db, fc := newFakeDB(true)
+	m := &audit{Model: &Model{}, Log: "login,"}
+	if err := db.Append(context.Background(), m, "a", []string{"Log"}, false, WithDurability(hbase.TDurability_ASYNC_WAL)).Error; err != nil {
+		t.Fatal(err)
+	}
+	app := fc.last(t, "append").(*hbase.THBaseServiceAppendArgs).Tappend
+	if app.Durability == nil || *app.Durability != hbase.TDurability_ASYNC_WAL {
+		t.Errorf("durability %v, expected ASYNC_WAL", app.Durability)
+	}
+	if len(app.Columns) != 1 || string(app.Columns[0].Qualifier) != "log" || string(app.Columns[0].Value) != "login," {
+		t.Errorf("unexpected columns %v", app.Columns)
+	}
+}
+
+func TestDeleteTimestampedVersion(t *testing.T) {
+	db, fc := newFakeDB(true)
+	selects := []Column{{Family: "info", Name: "email", Timestamp: 42}}
+	err := db.Delete(context.Background(), &account{}, "a", selects, WithDeleteType(hbase.TDeleteType_DELETE_COLUMN)).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	del := fc.last(t, "deleteSingle").(*hbase.THBaseServiceDeleteSingleArgs).Tdelete
+	if del.DeleteType != hbase.TDeleteType_DELETE_COLUMN || len(del.Columns) != 1 || del.Columns[0].GetTimestamp() != 42 {
+		t.Errorf("delete %s of %v, expected DELETE_COLUMN of the version at 42", del.DeleteType, del.Columns)
+	}
+}
+
+func TestUnsetDeletesAllVersions(t *testing.T) {
+	db, fc := newFakeDB(true)
+	m := &account{Model: &Model{Rowkey: "a"}, Email: "a@b.c"}
+	err := db.Mutate(context.Background(), m, WithDeleteType(hbase.TDeleteType_DELETE_COLUMN)).Set("Email").Unset("Age").Exec().Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	mutations := fc.last(t, "mutateRow").(*hbase.THBaseServiceMutateRowArgs).TrowMutations.Mutations
+	if len(mutations) != 2 || mutations[1].DeleteSingle == nil {
+		t.Fatalf("unexpected mutations %v", mutations)
+	}
+	if del := mutations[1].DeleteSingle; del.DeleteType != hbase.TDeleteType_DELETE_COLUMNS {
+		t.Errorf("Unset sent %s, expected DELETE_COLUMNS", del.DeleteType)
+	}
+}
+
+func TestIncrementAndAppendRejectVersionedModels(t *testing.T) {
+	db, fc := newFakeDB(true)
+	ctx := context.Background()
+	m := &versionedAccount{Model: &Model{Rowkey: "a"}, Email: "a@b.c", Ver: 3}
+	if err := db.Increment(ctx, m, "a", map[string]int64{"Ver": 1}, false).Error; !errors.Is(err, ErrInvalidModel) {
+		t.Errorf("Increment returned %v, expected ErrInvalidModel", err)
+	}
+	if err := db.Append(ctx, m, "a", []string{"Email"}, false).Error; !errors.Is(err, ErrInvalidModel) {
+		t.Errorf("Append returned %v, expected ErrInvalidModel", err)
+	}
+	if len(fc.calls) != 0 {
+		t.Errorf("versioned models were written by %v", fc.calls)
+	}
+}
+
+func TestGetManyKeepsRowkeyOrder(t *testing.T) {
+	db, fc := newFakeDB(true)
+	stored := map[string]string{"a": "a@b.c", "c": "c@b.c"}
+	fc.answer = func(ctx context.Context, method string, args, result thrift.TStruct) error {
+		if r, ok := result.(*hbase.THBaseServiceGetMultipleResult); ok {
+			// HBase answers every get in order, with an empty result for a missing row
+			for _, get := range args.(*hbase.THBaseServiceGetMultipleArgs).Tgets {
+				res := &hbase.TResult_{}
+				if email, ok := stored[string(get.Row)]; ok {
+					res.Row = get.Row
+					res.ColumnValues = []*hbase.TColumnValue{{Family: []byte("info"), Qualifier: []byte("email"), Value: []byte(email)}}
+				}
+				r.Success = append(r.Success, res)
+			}
+		}
+		return nil
+	}
+
+	var list []account
+	tx := db.GetMany(context.Background(), &list, []string{"c", "x", "a", "y"})
+	var missing *MissingRowsError
+	if !errors.As(tx.Error, &missing) || !errors.Is(tx.Error, ErrRecordNotFound) {
+		t.Fatalf("expected a *MissingRowsError, but got %v", tx.Error)
+	}
+	if len(missing.Rowkeys) != 2 || missing.Rowkeys[0] != "x" || missing.Rowkeys[1] != "y" {
+		t.Errorf("missing rowkeys %v, expected [x y]", missing.Rowkeys)
+	}
+	if tx.RowsAffected != 2 || len(list) != 2 {
+		t.Fatalf("got %d rows with RowsAffected %d, expected 2", len(list), tx.RowsAffected)
+	}
+	if list[0].Rowkey != "c" || list[0].Email != "c@b.c" || list[1].Rowkey != "a" || list[1].Email != "a@b.c" {
+		t.Errorf("rows %s %s, expected c then a", list[0].Rowkey, list[1].Rowkey)
+	}
+
+	list = nil
+	if tx = db.GetMany(context.Background(), &list, []string{"a"}); tx.Error != nil || len(list) != 1 {
+		t.Errorf("GetMany of an existing row returned %v with %d rows", tx.Error, len(list))
+	}
+}
+
+func TestExistsTakesReadOptions(t *testing.T) {
+	db, fc := newFakeDB(true)
+	fc.answer = func(ctx context.Context, method string, args, result thrift.TStruct) error {
+		switch r := result.(type) {
+		case *hbase.THBaseServiceExistsResult:
+			r.Success = new(bool)
+		case *hbase.THBaseServiceExistsAllResult:
+			r.Success = make([]bool, len(args.(*hbase.THBaseServiceExistsAllArgs).Tgets))
+		}
+		return nil
+	}
+	ctx := context.Background()
+	asOf := time.UnixMilli(1000)
+	if _, err := db.Exists(ctx, &account{}, "a", AsOf(asOf)); err != nil {
+		t.Fatal(err)
+	}
+	if get := fc.last(t, "exists").(*hbase.THBaseServiceExistsArgs).Tget; get.TimeRange == nil || get.TimeRange.MaxStamp != 1001 {
+		t.Errorf("time range %v, expected up to 1001", get.TimeRange)
+	}
+	if _, err := db.ExistsAll(ctx, &account{}, []string{"a", "b"}, Timeline()); err != nil {
+		t.Fatal(err)
+	}
+	for _, get := range fc.last(t, "existsAll").(*hbase.THBaseServiceExistsAllArgs).Tgets {
+		if get.GetConsistency() != hbase.TConsistency_TIMELINE {
+			t.Errorf("consistency %v, expected TIMELINE", get.Consistency)
+		}
+	}
+}
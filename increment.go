@@ -0,0 +1,88 @@
+package horm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/challenai/horm/thrift/hbase"
+)
+
+// atomically add deltas to the counter columns of a row, deltas are keyed by go field names:
+//
+//	db.Increment(ctx, &Post{}, rowkey, map[string]int64{"Views": 1}, true)
+//
+// HBase stores counters as 8 bytes big-endian integers, which is the encoding of codec.DefaultCodec.
+// with returnResults the new values are decoded into model.
+// versioned models are rejected, an increment can't check their version, update them with Transact instead.
+func (h *DB) Increment(ctx context.Context, model interface{}, rowkey string, deltas map[string]int64, returnResults bool, opts ...Option) *DB {
+	tx := h.session()
+	tb, err := tableOf(model)
+	if err != nil {
+		tx.Error = err
+		return tx
+	}
+	schm, err := h.loadSchema(reflect.TypeOf(model).Elem())
+	if err != nil {
+		tx.Error = err
+		return tx
+	}
+	if schm.version != nil {
+		tx.Error = fmt.Errorf("%w: %T has a version field, increment it with Transact", ErrInvalidModel, model)
+		return tx
+	}
+	if len(deltas) == 0 {
+		tx.Error = fmt.Errorf("nothing to increment on row %s", rowkey)
+		return tx
+	}
+
+	// sort the fields so the same deltas always build the same request
+	names := make([]string, 0, len(deltas))
+	for name := range deltas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	inc := &hbase.TIncrement{
+		Row:           []byte(rowkey),
+		Columns:       make([]*hbase.TColumnIncrement, 0, len(names)),
+		ReturnResults: &returnResults,
+	}
+	fields := make([]*field, 0, len(names))
+	for _, name := range names {
+		f := schm.fieldByName(name)
+		if f == nil {
+			tx.Error = fmt.Errorf("%w: field %s is not mapped by %T", ErrUnknownColumn, name, model)
+			return tx
+		}
+		if !isInteger(f.typ) {
+			tx.Error = fmt.Errorf("field %s of %T can't be incremented, it's not an integer", name, model)
+			return tx
+		}
+		fields = append(fields, f)
+		inc.Columns = append(inc.Columns, &hbase.TColumnIncrement{
+			Family:    f.family,
+			Qualifier: f.qualifier,
+			Amount:    deltas[name],
+		})
+	}
+
+	h.newOptions(opts).applyIncrement(inc)
+	if inc.CellVisibility == nil {
+		if inc.CellVisibility, err = sharedVisibility(fields); err != nil {
+			tx.Error = err
+			return tx
+		}
+	}
+	result, err := tx.db.Increment(ctx, []byte(fmt.Sprintf("%s:%s", tb.Namespace(), tb.TableName())), inc)
+	if err != nil {
+		tx.Error = err
+		return tx
+	}
+	tx.RowsAffected = 1
+	if returnResults && result != nil {
+		value := reflect.ValueOf(model).Elem()
+		tx.Error = h.retrieveValue(&value, result)
+	}
+	return tx
+}
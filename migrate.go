@@ -0,0 +1,207 @@
+package horm
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/challenai/horm/thrift/hbase"
+)
+
+// create the namespaces, tables and column families of models which don't exist yet:
+//
+//	err := db.AutoMigrate(ctx, &User{}, &Order{}).Error
+//
+// the namespace and table are taken from the Table interface and the families from the horm tags,
+// new families are created with the options declared by the Families interface.
+// AutoMigrate never drops or modifies anything, so families which are no longer mapped are kept.
+// RowsAffected is the number of namespaces, tables and families created.
+func (h *DB) AutoMigrate(ctx context.Context, models ...interface{}) *DB {
+	tx := h.session()
+	// check all the models before touching the cluster
+	tables := make([]Table, 0, len(models))
+	modelFamilies := make([][]*hbase.TColumnFamilyDescriptor, 0, len(models))
+	for _, model := range models {
+		tb, err := tableOf(model)
+		if err != nil {
+			tx.Error = err
+			return tx
+		}
+		schm, err := h.loadSchema(reflect.TypeOf(model).Elem())
+		if err != nil {
+			tx.Error = err
+			return tx
+		}
+		families, err := familiesOf(model, schm)
+		if err != nil {
+			tx.Error = err
+			return tx
+		}
+		if len(families) == 0 {
+			tx.Error = fmt.Errorf("%w: %T doesn't map any column", ErrInvalidModel, model)
+			return tx
+		}
+		tables = append(tables, tb)
+		modelFamilies = append(modelFamilies, families)
+	}
+
+	namespaces, err := tx.db.ListNamespaces(ctx)
+	if err != nil {
+		tx.Error = err
+		return tx
+	}
+	existing := map[string]bool{}
+	for _, ns := range namespaces {
+		existing[ns] = true
+	}
+
+	for i, tb := range tables {
+		families := modelFamilies[i]
+		if !existing[tb.Namespace()] {
+			if err = tx.db.CreateNamespace(ctx, &hbase.TNamespaceDescriptor{Name: tb.Namespace()}); err != nil {
+				tx.Error = fmt.Errorf("create namespace %s: %w", tb.Namespace(), err)
+				return tx
+			}
+			existing[tb.Namespace()] = true
+			tx.RowsAffected++
+		}
+
+		tableName := &hbase.TTableName{
+			Ns:        []byte(tb.Namespace()),
+			Qualifier: []byte(tb.TableName()),
+		}
+		ok, err := tx.db.TableExists(ctx, tableName)
+		if err != nil {
+			tx.Error = err
+			return tx
+		}
+		if !ok {
+			desc := &hbase.TTableDescriptor{
+				TableName: tableName,
+				Columns:   families,
+			}
+			if err = tx.db.CreateTable(ctx, desc, nil); err != nil {
+				tx.Error = fmt.Errorf("create table %s:%s: %w", tb.Namespace(), tb.TableName(), err)
+				return tx
+			}
+			tx.RowsAffected++
+			continue
+		}
+
+		desc, err := tx.db.GetTableDescriptor(ctx, tableName)
+		if err != nil {
+			tx.Error = err
+			return tx
+		}
+		live := map[string]bool{}
+		for _, col := range desc.Columns {
+			live[string(col.Name)] = true
+		}
+		for _, family := range families {
+			if live[string(family.Name)] {
+				continue
+			}
+			if err = tx.db.AddColumnFamily(ctx, tableName, family); err != nil {
+				tx.Error = fmt.Errorf("add column family %s to %s:%s: %w", family.Name, tb.Namespace(), tb.TableName(), err)
+				return tx
+			}
+			tx.RowsAffected++
+		}
+	}
+	return tx
+}
+
+// the column families mapped by the schema in declaration order.
+func (s *schema) families() []string {
+	var families []string
+	seen := map[string]bool{}
+	for _, f := range s.fields {
+		if !seen[string(f.family)] {
+			seen[string(f.family)] = true
+			families = append(families, string(f.family))
+		}
+	}
+	return families
+}
+
+// the descriptors of the families expected by model, the mapped families in declaration order
+// are followed by the families only declared by the Families interface in name order.
+func familiesOf(model interface{}, schm *schema) ([]*hbase.TColumnFamilyDescriptor, error) {
+	var declared map[string]FamilyOptions
+	if fs, ok := model.(Families); ok {
+		declared = fs.FamilyOptions()
+	}
+	names := schm.families()
+	var extra []string
+	for name := range declared {
+		if _, ok := schm.columns[name]; !ok {
+			extra = append(extra, name)
+		}
+	}
+	sort.Strings(extra)
+	names = append(names, extra...)
+
+	descs := make([]*hbase.TColumnFamilyDescriptor, 0, len(names))
+	for _, name := range names {
+		desc, err := familyDescriptor(name, declared[name])
+		if err != nil {
+			return nil, fmt.Errorf("%w: family %s of %T: %v", ErrInvalidModel, name, model, err)
+		}
+		descs = append(descs, desc)
+	}
+	return descs, nil
+}
+
+// build the descriptor of a column family, options which are not set are left to the server.
+func familyDescriptor(family string, opts FamilyOptions) (*hbase.TColumnFamilyDescriptor, error) {
+	desc := &hbase.TColumnFamilyDescriptor{Name: []byte(family)}
+	if opts.MaxVersions > 0 {
+		desc.MaxVersions = &opts.MaxVersions
+	}
+	if opts.MinVersions > 0 {
+		desc.MinVersions = &opts.MinVersions
+	}
+	if opts.TTL > 0 {
+		if opts.TTL < time.Second {
+			return nil, fmt.Errorf("ttl %s is shorter than a second", opts.TTL)
+		}
+		// the TTL of HBase is an int of seconds, where MaxInt32 means forever
+		ttl := int32(math.MaxInt32)
+		if opts.TTL/time.Second < math.MaxInt32 {
+			ttl = int32(opts.TTL / time.Second)
+		}
+		desc.TimeToLive = &ttl
+	}
+	if opts.Compression != "" {
+		compression, err := hbase.TCompressionAlgorithmFromString(strings.ToUpper(opts.Compression))
+		if err != nil {
+			return nil, err
+		}
+		desc.CompressionType = &compression
+	}
+	if opts.BloomFilter != "" {
+		bloom, err := hbase.TBloomFilterTypeFromString(strings.ToUpper(opts.BloomFilter))
+		if err != nil {
+			return nil, err
+		}
+		desc.BloomnFilterType = &bloom
+	}
+	if opts.BlockSize > 0 {
+		desc.BlockSize = &opts.BlockSize
+	}
+	if opts.InMemory {
+		desc.InMemory = &opts.InMemory
+	}
+	if opts.DataBlockEncoding != "" {
+		encoding, err := hbase.TDataBlockEncodingFromString(strings.ToUpper(opts.DataBlockEncoding))
+		if err != nil {
+			return nil, err
+		}
+		desc.DataBlockEncoding = &encoding
+	}
+	return desc, nil
+}
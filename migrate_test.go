@@ -0,0 +1,150 @@
+package horm
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/challenai/horm/thrift/hbase"
+)
+
+type contact struct {
+	*Model
+	Email string `horm:"info,email"`
+	Phone string `horm:"pii,phone"`
+}
+
+func (*contact) Namespace() string { return "test" }
+func (*contact) TableName() string { return "contact" }
+
+type shortLived struct {
+	*Model
+	Token string `horm:"s,token"`
+}
+
+func (*shortLived) Namespace() string { return "test" }
+func (*shortLived) TableName() string { return "session" }
+func (*shortLived) FamilyOptions() map[string]FamilyOptions {
+	return map[string]FamilyOptions{"s": {TTL: time.Millisecond}}
+}
+
+// answer the admin calls of a cluster with the namespaces and the families of the existing tables.
+func fakeCluster(namespaces []string, tables map[string][]string) func(ctx context.Context, method string, args, result thrift.TStruct) error {
+	return func(ctx context.Context, method string, args, result thrift.TStruct) error {
+		switch r := result.(type) {
+		case *hbase.THBaseServiceListNamespacesResult:
+			r.Success = namespaces
+		case *hbase.THBaseServiceTableExistsResult:
+			tn := args.(*hbase.THBaseServiceTableExistsArgs).TableName
+			_, ok := tables[string(tn.Ns)+":"+string(tn.Qualifier)]
+			r.Success = &ok
+		case *hbase.THBaseServiceGetTableDescriptorResult:
+			tn := args.(*hbase.THBaseServiceGetTableDescriptorArgs).Table
+			desc := &hbase.TTableDescriptor{TableName: tn}
+			for _, family := range tables[string(tn.Ns)+":"+string(tn.Qualifier)] {
+				desc.Columns = append(desc.Columns, &hbase.TColumnFamilyDescriptor{Name: []byte(family)})
+			}
+			r.Success = desc
+		}
+		return nil
+	}
+}
+
+func TestAutoMigrateAddsMissingFamilies(t *testing.T) {
+	db, fc := newFakeDB(true)
+	fc.answer = fakeCluster([]string{"test"}, map[string][]string{"test:contact": {"info", "old"}})
+	tx := db.AutoMigrate(context.Background(), &contact{}, &account{})
+	if tx.Error != nil {
+		t.Fatal(tx.Error)
+	}
+	var changes []string
+	for i, method := range fc.calls {
+		switch args := fc.args[i].(type) {
+		case *hbase.THBaseServiceAddColumnFamilyArgs:
+			changes = append(changes, method+" "+string(args.Column.Name))
+		case *hbase.THBaseServiceCreateTableArgs:
+			changes = append(changes, method+" "+string(args.Desc.TableName.Qualifier))
+		case *hbase.THBaseServiceCreateNamespaceArgs, *hbase.THBaseServiceModifyColumnFamilyArgs, *hbase.THBaseServiceDeleteColumnFamilyArgs:
+			changes = append(changes, method)
+		}
+	}
+	if len(changes) != 2 || changes[0] != "addColumnFamily pii" || changes[1] != "createTable account" {
+		t.Errorf("changes %q, expected [addColumnFamily pii, createTable account]", changes)
+	}
+	if tx.RowsAffected != 2 {
+		t.Errorf("RowsAffected is %d, expected 2", tx.RowsAffected)
+	}
+}
+
+func TestAutoMigrateChecksModelsFirst(t *testing.T) {
+	db, fc := newFakeDB(true)
+	fc.answer = fakeCluster(nil, nil)
+	invalid := []interface{}{&struct{ Name string }{}, &unexportedField{}, nil}
+	for _, model := range invalid {
+		if err := db.AutoMigrate(context.Background(), &contact{}, model).Error; err == nil {
+			t.Errorf("AutoMigrate of %T should fail", model)
+		}
+	}
+	if len(fc.calls) != 0 {
+		t.Errorf("invalid models made calls %v", fc.calls)
+	}
+	if err := db.AutoMigrate(context.Background(), &shortLived{}).Error; !errors.Is(err, ErrInvalidModel) {
+		t.Errorf("expected ErrInvalidModel, but got %v", err)
+	}
+	if len(fc.calls) != 0 {
+		t.Errorf("invalid family options made calls %v", fc.calls)
+	}
+}
+
+func TestFamilyDescriptor(t *testing.T) {
+	desc, err := familyDescriptor("f", FamilyOptions{
+		MaxVersions:       3,
+		TTL:               90*time.Second + 500*time.Millisecond,
+		Compression:       "snappy",
+		BloomFilter:       "ROWCOL",
+		DataBlockEncoding: "fast_diff",
+		InMemory:          true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc.GetMaxVersions() != 3 || desc.MinVersions != nil || desc.BlockSize != nil {
+		t.Errorf("versions %v..%v and block size %v, expected only max versions 3", desc.MinVersions, desc.MaxVersions, desc.BlockSize)
+	}
+	if desc.GetTimeToLive() != 90 {
+		t.Errorf("ttl %d, expected 90 seconds", desc.GetTimeToLive())
+	}
+	if desc.GetCompressionType() != hbase.TCompressionAlgorithm_SNAPPY || desc.GetBloomnFilterType() != hbase.TBloomFilterType_ROWCOL ||
+		desc.GetDataBlockEncoding() != hbase.TDataBlockEncoding_FAST_DIFF || !desc.GetInMemory() {
+		t.Errorf("unexpected descriptor %v", desc)
+	}
+
+	// longer TTLs are capped to forever
+	desc, err = familyDescriptor("f", FamilyOptions{TTL: time.Duration(math.MaxInt64)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if desc.GetTimeToLive() != math.MaxInt32 {
+		t.Errorf("ttl %d, expected MaxInt32", desc.GetTimeToLive())
+	}
+
+	desc, err = familyDescriptor("f", FamilyOptions{})
+	if err != nil || !isBareFamily(desc) {
+		t.Errorf("zero options built %v, %v", desc, err)
+	}
+
+	invalid := []FamilyOptions{
+		{TTL: 999 * time.Millisecond},
+		{Compression: "zip"},
+		{BloomFilter: "ROWS"},
+		{DataBlockEncoding: "DELTA"},
+	}
+	for _, opts := range invalid {
+		if desc, err := familyDescriptor("f", opts); err == nil {
+			t.Errorf("options %+v built %v", opts, desc)
+		}
+	}
+}
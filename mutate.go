@@ -0,0 +1,156 @@
+package horm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/challenai/horm/thrift/hbase"
+)
+
+// Mutation atomically puts and deletes columns of a single row, create it with DB.Mutate:
+//
+//	err := db.Mutate(ctx, job).Set("Result").Unset("Pending").Exec().Error
+//
+// the row is the rowkey of model and the written values are taken from model.
+type Mutation struct {
+	h      *DB
+	ctx    context.Context
+	model  interface{}
+	sets   []string
+	unsets []string
+	opts   []Option
+}
+
+// start an atomic mutation on the row of model.
+func (h *DB) Mutate(ctx context.Context, model interface{}, opts ...Option) *Mutation {
+	return &Mutation{
+		h:     h,
+		ctx:   ctx,
+		model: model,
+		opts:  opts,
+	}
+}
+
+// Set writes the go fields of model.
+func (m *Mutation) Set(fields ...string) *Mutation {
+	m.sets = append(m.sets, fields...)
+	return m
+}
+
+// Unset deletes all the versions of the columns mapped by the go fields.
+func (m *Mutation) Unset(fields ...string) *Mutation {
+	m.unsets = append(m.unsets, fields...)
+	return m
+}
+
+// Exec applies all the mutations atomically with mutateRow.
+// a versioned model is checked and bumped like Set, ErrStaleObject is returned on conflict.
+func (m *Mutation) Exec() *DB {
+	tx := m.h.session()
+	tb, err := tableOf(m.model)
+	if err != nil {
+		tx.Error = err
+		return tx
+	}
+	value := reflect.ValueOf(m.model).Elem()
+	schm, err := m.h.loadSchema(value.Type())
+	if err != nil {
+		tx.Error = err
+		return tx
+	}
+	rowkey, err := rowkeyOf(value)
+	if err != nil {
+		tx.Error = err
+		return tx
+	}
+	if len(m.sets) == 0 && len(m.unsets) == 0 {
+		tx.Error = fmt.Errorf("nothing to mutate on row %s", rowkey)
+		return tx
+	}
+
+	row := []byte(rowkey)
+	put := &hbase.TPut{Row: row}
+	set := map[string]bool{}
+	for _, name := range m.sets {
+		f := schm.fieldByName(name)
+		if f == nil {
+			tx.Error = fmt.Errorf("%w: field %s is not mapped by %T", ErrUnknownColumn, name, m.model)
+			return tx
+		}
+		set[name] = true
+		putColumn(put, f.columnValue(value))
+	}
+	del := &hbase.TDelete{Row: row}
+	for _, name := range m.unsets {
+		f := schm.fieldByName(name)
+		if f == nil {
+			tx.Error = fmt.Errorf("%w: field %s is not mapped by %T", ErrUnknownColumn, name, m.model)
+			return tx
+		}
+		if set[name] {
+			tx.Error = fmt.Errorf("field %s of %T is both set and unset", name, m.model)
+			return tx
+		}
+		if f == schm.version {
+			tx.Error = fmt.Errorf("version field %s of %T can't be unset", name, m.model)
+			return tx
+		}
+		del.Columns = append(del.Columns, &hbase.TColumn{
+			Family:    f.family,
+			Qualifier: f.qualifier,
+		})
+	}
+
+	var next reflect.Value
+	if schm.version != nil {
+		next = nextVersion(schm.version, value, put)
+	}
+	mutations := &hbase.TRowMutations{Row: row}
+	if len(put.ColumnValues) > 0 {
+		mutations.Mutations = append(mutations.Mutations, &hbase.TMutation{Put: put})
+	}
+	if len(del.Columns) > 0 {
+		mutations.Mutations = append(mutations.Mutations, &hbase.TMutation{DeleteSingle: del})
+	}
+	m.h.newOptions(m.opts).applyMutations(mutations, value)
+	// Unset always deletes all the versions, whatever WithDeleteType says
+	del.DeleteType = hbase.TDeleteType_DELETE_COLUMNS
+
+	table := []byte(fmt.Sprintf("%s:%s", tb.Namespace(), tb.TableName()))
+	if schm.version == nil {
+		schm.splitMutations(mutations)
+		err = tx.db.MutateRow(m.ctx, table, mutations)
+	} else {
+		err = tx.checkVersionAndMutate(m.ctx, table, schm, value, mutations)
+	}
+	if err != nil {
+		tx.Error = err
+		return tx
+	}
+	if next.IsValid() {
+		value.Field(schm.version.index).Set(next)
+	}
+	tx.RowsAffected = 1
+	return tx
+}
+
+// apply mutations only if the version column still holds the version of model.
+func (h *DB) checkVersionAndMutate(ctx context.Context, table []byte, schm *schema, value reflect.Value, mutations *hbase.TRowMutations) error {
+	f := schm.version
+	schm.splitMutations(mutations)
+	current := value.Field(f.index)
+	var expected []byte
+	// a zero version means the model has never been written, so the column should be absent
+	if !current.IsZero() {
+		expected = f.encode(current)
+	}
+	ok, err := h.db.CheckAndMutate(ctx, table, mutations.Row, f.family, f.qualifier, hbase.TCompareOperator_EQUAL, expected, mutations)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrStaleObject
+	}
+	return nil
+}
@@ -0,0 +1,270 @@
+package horm
+
+import (
+	"encoding/binary"
+	"reflect"
+	"time"
+
+	"github.com/challenai/horm/thrift/hbase"
+)
+
+const (
+	defaultRetries = 3
+	defaultBackoff = 10 * time.Millisecond
+	ttlAttribute   = "_ttl"
+)
+
+// Option customizes a single operation, options which don't apply to the operation are ignored.
+// use DB.With to apply options to all the operations of a DB.
+type Option func(*options)
+
+type options struct {
+	retries int
+	backoff time.Duration
+	asOf    time.Time
+	// write timestamp of puts
+	timestamp     time.Time
+	rowTimestamps bool
+	// applied to all the mutations
+	durability *hbase.TDurability
+	ttl        time.Duration
+	attributes map[string][]byte
+	visibility *hbase.TCellVisibility
+	deleteType hbase.TDeleteType
+	// applied to all the reads
+	authorizations *hbase.TAuthorization
+	consistency    *hbase.TConsistency
+	replica        *int32
+}
+
+// collect the options of the DB and then the options of the call over the defaults.
+func (h *DB) newOptions(opts []Option) *options {
+	o := &options{
+		retries:    defaultRetries,
+		backoff:    defaultBackoff,
+		deleteType: hbase.TDeleteType_DELETE_COLUMNS,
+	}
+	for _, opt := range h.opts {
+		opt(o)
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// AsOf reads the rows as they were at t, every column reflects its latest version written at or before t.
+// it applies to Get, GetMany, Exists, ExistsAll, Find and Rows.
+func AsOf(t time.Time) Option {
+	return func(o *options) {
+		o.asOf = t
+	}
+}
+
+// WithTimestamp writes the cells at t instead of the server time, so that replaying a write is idempotent.
+// it applies to all the puts.
+func WithTimestamp(t time.Time) Option {
+	return func(o *options) {
+		o.timestamp = t
+	}
+}
+
+// WithRowTimestamps writes every model at its own Model.Timestamp, a zero Timestamp means the server time.
+// older writes never shadow newer cells, which makes last-write-wins merges possible.
+// it applies to all the puts and takes precedence over WithTimestamp.
+func WithRowTimestamps() Option {
+	return func(o *options) {
+		o.rowTimestamps = true
+	}
+}
+
+// WithDurability sets how the mutations are written to the WAL, like hbase.TDurability_ASYNC_WAL.
+// it applies to all the mutations.
+func WithDurability(d hbase.TDurability) Option {
+	return func(o *options) {
+		o.durability = &d
+	}
+}
+
+// WithDeleteType sets which versions of the selected columns are deleted, all of them by default,
+// hbase.TDeleteType_DELETE_COLUMN only deletes the latest version, or the version at the Timestamp of a Column.
+// it applies to all the deletes but Mutation.Unset.
+func WithDeleteType(t hbase.TDeleteType) Option {
+	return func(o *options) {
+		o.deleteType = t
+	}
+}
+
+// WithTTL expires the written cells after ttl, it's honored by HBase 0.98+ and applies to all the mutations
+// but deletes. the cell TTL can only shorten the TTL of the column family.
+func WithTTL(ttl time.Duration) Option {
+	return func(o *options) {
+		o.ttl = ttl
+	}
+}
+
+// WithAttribute attaches an attribute to the mutations, it's visible to the coprocessors on the server.
+// it applies to all the mutations.
+func WithAttribute(key string, value []byte) Option {
+	return func(o *options) {
+		if o.attributes == nil {
+			o.attributes = map[string][]byte{}
+		}
+		o.attributes[key] = value
+	}
+}
+
+// WithVisibility labels the written cells with a visibility expression like "PII&!CONTRACTOR",
+// it overrides the visibility tag options of the fields and applies to puts, increments and appends.
+func WithVisibility(expr string) Option {
+	return func(o *options) {
+		o.visibility = &hbase.TCellVisibility{Expression: &expr}
+	}
+}
+
+// WithAuthorizations reads with the visibility labels the caller is cleared for,
+// cells whose visibility expression isn't satisfied by labels are skipped.
+// it applies to all the reads, it's usually set once per caller with DB.With.
+func WithAuthorizations(labels ...string) Option {
+	return func(o *options) {
+		o.authorizations = &hbase.TAuthorization{Labels: labels}
+	}
+}
+
+// Strong reads from the primary replica of the region, it's the default of HBase.
+// it applies to all the reads and overrides a Timeline or Replica set on the DB.
+func Strong() Option {
+	return func(o *options) {
+		c := hbase.TConsistency_STRONG
+		o.consistency, o.replica = &c, nil
+	}
+}
+
+// Timeline reads from any replica of the region, which keeps reads available while the primary
+// replica is recovering. data served by a secondary replica may be stale, it's reported by Model.Stale
+// and the Stale of the returned DB. it applies to all the reads.
+func Timeline() Option {
+	return func(o *options) {
+		c := hbase.TConsistency_TIMELINE
+		o.consistency, o.replica = &c, nil
+	}
+}
+
+// Replica reads from the replica id of the region with timeline consistency, 0 is the primary replica.
+// it applies to all the reads.
+func Replica(id int32) Option {
+	return func(o *options) {
+		c := hbase.TConsistency_TIMELINE
+		o.consistency, o.replica = &c, &id
+	}
+}
+
+// WithRetries sets how many times Transact retries on conflict,
+// it waits backoff before the first retry and doubles the wait for each next one.
+func WithRetries(n int, backoff time.Duration) Option {
+	return func(o *options) {
+		o.retries = n
+		o.backoff = backoff
+	}
+}
+
+// time range covering all the versions written at or before t, the max stamp is exclusive.
+func asOfRange(t time.Time) *hbase.TTimeRange {
+	return &hbase.TTimeRange{
+		MinStamp: 0,
+		MaxStamp: t.UnixMilli() + 1,
+	}
+}
+
+// apply the read options to a thrift get.
+func (o *options) applyGet(tGet *hbase.TGet) {
+	if !o.asOf.IsZero() {
+		tGet.TimeRange = asOfRange(o.asOf)
+	}
+	tGet.Authorizations = o.authorizations
+	tGet.Consistency = o.consistency
+	tGet.TargetReplicaId = o.replica
+}
+
+// apply the read options to a thrift scan.
+func (o *options) applyScan(tScan *hbase.TScan) {
+	if !o.asOf.IsZero() {
+		tScan.TimeRange = asOfRange(o.asOf)
+	}
+	tScan.Authorizations = o.authorizations
+	tScan.Consistency = o.consistency
+	tScan.TargetReplicaId = o.replica
+}
+
+// apply the write options to the put of a model value.
+func (o *options) applyPut(put *hbase.TPut, value reflect.Value) {
+	put.Durability = o.durability
+	put.Attributes = o.mutationAttributes(true)
+	if o.visibility != nil {
+		put.CellVisibility = o.visibility
+	}
+	ts := o.timestamp
+	if o.rowTimestamps {
+		ts = time.Time{}
+		if base, ok := value.FieldByName(ModelName).Interface().(*Model); ok && base != nil {
+			ts = base.Timestamp
+		}
+	}
+	if !ts.IsZero() {
+		stamp := ts.UnixMilli()
+		put.Timestamp = &stamp
+	}
+}
+
+// apply the write options to a thrift delete.
+func (o *options) applyDelete(del *hbase.TDelete) {
+	del.DeleteType = o.deleteType
+	del.Durability = o.durability
+	del.Attributes = o.mutationAttributes(false)
+}
+
+// apply the write options to a thrift increment.
+func (o *options) applyIncrement(inc *hbase.TIncrement) {
+	inc.Durability = o.durability
+	inc.Attributes = o.mutationAttributes(true)
+	if o.visibility != nil {
+		inc.CellVisibility = o.visibility
+	}
+}
+
+// apply the write options to a thrift append.
+func (o *options) applyAppend(app *hbase.TAppend) {
+	app.Durability = o.durability
+	app.Attributes = o.mutationAttributes(true)
+	if o.visibility != nil {
+		app.CellVisibility = o.visibility
+	}
+}
+
+// apply the write options to the puts and deletes of a row mutation.
+func (o *options) applyMutations(mutations *hbase.TRowMutations, value reflect.Value) {
+	for _, m := range mutations.Mutations {
+		if m.Put != nil {
+			o.applyPut(m.Put, value)
+		}
+		if m.DeleteSingle != nil {
+			o.applyDelete(m.DeleteSingle)
+		}
+	}
+}
+
+// attributes sent with a mutation, HBase reads the cell TTL from the _ttl attribute as 8 bytes big-endian milliseconds.
+func (o *options) mutationAttributes(ttl bool) map[string][]byte {
+	if len(o.attributes) == 0 && (!ttl || o.ttl <= 0) {
+		return nil
+	}
+	attrs := make(map[string][]byte, len(o.attributes)+1)
+	for k, v := range o.attributes {
+		attrs[k] = v
+	}
+	if ttl && o.ttl > 0 {
+		attrs[ttlAttribute] = make([]byte, 8)
+		binary.BigEndian.PutUint64(attrs[ttlAttribute], uint64(o.ttl.Milliseconds()))
+	}
+	return attrs
+}
@@ -0,0 +1,217 @@
+package horm
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/challenai/horm/thrift/hbase"
+)
+
+// Query is a chainable scan builder, create it with DB.Model:
+//
+//	err := db.Model(&User{}).Prefix("u_").Select("Name", "Age").Limit(100).Reverse().Find(ctx, &users).Error
+//
+// every Query is used by a single call chain, don't share it between goroutines.
+type Query struct {
+	h           *DB
+	model       interface{}
+	startRow    []byte
+	stopRow     []byte
+	prefix      []byte
+	selects     []string
+	filters     []Expr
+	limit       int32
+	reversed    bool
+	caching     int32
+	maxVersions int32
+	timeRange   *hbase.TTimeRange
+}
+
+// start a scan query on the table of model, model should be a struct pointer like &User{}.
+func (h *DB) Model(model interface{}) *Query {
+	return &Query{
+		h:     h,
+		model: model,
+	}
+}
+
+// Prefix only scans rows whose rowkey starts with prefix, it replaces Range.
+func (q *Query) Prefix(prefix string) *Query {
+	q.prefix = []byte(prefix)
+	q.startRow, q.stopRow = nil, nil
+	return q
+}
+
+// Range scans rows from start (inclusive) to stop (exclusive), an empty string means unbounded.
+// it follows the scan direction, so start should be the greater row of a reversed scan.
+func (q *Query) Range(start, stop string) *Query {
+	q.startRow, q.stopRow = []byte(start), []byte(stop)
+	q.prefix = nil
+	return q
+}
+
+// Select only reads the columns mapped by the go fields, like Select("Name", "Age").
+func (q *Query) Select(fields ...string) *Query {
+	q.selects = append(q.selects, fields...)
+	return q
+}
+
+// Where filters rows with a filter expression, like Where(horm.Ge("Age", 18)),
+// use horm.Raw for a hand-written filter string. multiple conditions are combined with AND.
+func (q *Query) Where(filter Expr) *Query {
+	q.filters = append(q.filters, filter)
+	return q
+}
+
+// Limit returns at most n rows.
+func (q *Query) Limit(n int32) *Query {
+	q.limit = n
+	return q
+}
+
+// Reverse scans rows in descending rowkey order.
+func (q *Query) Reverse() *Query {
+	q.reversed = true
+	return q
+}
+
+// Caching sets how many rows the region server fetches per RPC.
+func (q *Query) Caching(n int32) *Query {
+	q.caching = n
+	return q
+}
+
+// MaxVersions sets how many versions of each column the server returns.
+func (q *Query) MaxVersions(n int32) *Query {
+	q.maxVersions = n
+	return q
+}
+
+// TimeRange only reads cells written in [from, to).
+func (q *Query) TimeRange(from, to time.Time) *Query {
+	q.timeRange = &hbase.TTimeRange{
+		MinStamp: from.UnixMilli(),
+		MaxStamp: to.UnixMilli(),
+	}
+	return q
+}
+
+// AsOf reads the rows as they were at t, every column reflects its latest version written at or before t.
+func (q *Query) AsOf(t time.Time) *Query {
+	q.timeRange = asOfRange(t)
+	return q
+}
+
+// Find decodes all the matched rows into list, list should be a slice pointer like *[]User.
+// the model of the query can be omitted, it's derived from list in that case.
+func (q *Query) Find(ctx context.Context, list interface{}) *DB {
+	tx := q.h.session()
+	modelType, err := listModelType(list)
+	if err != nil {
+		tx.Error = err
+		return tx
+	}
+	if q.model != nil && reflect.TypeOf(q.model) != reflect.PtrTo(modelType) {
+		tx.Error = fmt.Errorf("%w: query model %T doesn't match list %T", ErrInvalidModel, q.model, list)
+		return tx
+	}
+	q.model = reflect.New(modelType).Interface()
+
+	rows, err := q.Rows(ctx)
+	if err != nil {
+		tx.Error = err
+		return tx
+	}
+	tx.Error = scanAll(rows, list)
+	tx.Stale = rows.stale
+	return tx
+}
+
+// Rows opens an iterator over the matched rows.
+func (q *Query) Rows(ctx context.Context) (*Rows, error) {
+	tb, err := tableOf(q.model)
+	if err != nil {
+		return nil, err
+	}
+	tScan, err := q.build()
+	if err != nil {
+		return nil, err
+	}
+	return q.h.openRows(ctx, tb, tScan, q.limit)
+}
+
+// compile the query to a thrift scan.
+func (q *Query) build() (*hbase.TScan, error) {
+	tScan := hbase.NewTScan()
+	q.h.newOptions(nil).applyScan(tScan)
+	tScan.StartRow, tScan.StopRow = q.startRow, q.stopRow
+	if q.timeRange != nil {
+		tScan.TimeRange = q.timeRange
+	}
+	if q.maxVersions > 0 {
+		tScan.MaxVersions = q.maxVersions
+	}
+	schm, err := q.h.loadSchema(reflect.TypeOf(q.model).Elem())
+	if err != nil {
+		return nil, err
+	}
+	filters := q.filters
+	if q.prefix != nil {
+		prefixEnd := rowPrefixEnd(q.prefix)
+		if !q.reversed {
+			tScan.StartRow, tScan.StopRow = q.prefix, prefixEnd
+		} else {
+			// there isn't a closest row before prefixEnd, start from it and let the
+			// prefix filter drop the row equal to it and stop once the prefix is passed
+			tScan.StartRow = prefixEnd
+			filters = append([]Expr{RowPrefix(string(q.prefix))}, filters...)
+		}
+	}
+	if len(filters) > 0 {
+		filter, err := And(filters...).render(schm)
+		if err != nil {
+			return nil, err
+		}
+		tScan.FilterString = []byte(filter)
+	}
+	if q.limit > 0 {
+		limit := q.limit
+		tScan.Limit = &limit
+	}
+	if q.reversed {
+		reversed := true
+		tScan.Reversed = &reversed
+	}
+	if q.caching > 0 {
+		caching := q.caching
+		tScan.Caching = &caching
+	}
+	if len(q.selects) > 0 {
+		tScan.Columns = make([]*hbase.TColumn, 0, len(q.selects))
+		for _, name := range q.selects {
+			f := schm.fieldByName(name)
+			if f == nil {
+				return nil, fmt.Errorf("%w: field %s is not mapped by %T", ErrUnknownColumn, name, q.model)
+			}
+			tScan.Columns = append(tScan.Columns, &hbase.TColumn{
+				Family:    f.family,
+				Qualifier: f.qualifier,
+			})
+		}
+	}
+	return tScan, nil
+}
+
+// get the first row after all the rows starting with prefix, nil means the end of table.
+func rowPrefixEnd(prefix []byte) []byte {
+	end := append([]byte{}, prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] != 0xff {
+			end[i]++
+			return end[:i+1]
+		}
+	}
+	return nil
+}
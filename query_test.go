@@ -0,0 +1,74 @@
+package horm
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestQueryBuild(t *testing.T) {
+	db, _ := newFakeDB(true)
+	email := "SingleColumnValueFilter('info', 'email', =, 'binary:a@b.c', true, true)"
+	cases := []struct {
+		name        string
+		query       *Query
+		start, stop string
+		filter      string
+	}{
+		{"prefix", db.Model(&account{}).Prefix("u_"), "u_", "u`", ""},
+		{"prefix ending with 0xff", db.Model(&account{}).Prefix("a\xff"), "a\xff", "b", ""},
+		{"prefix of 0xff", db.Model(&account{}).Prefix("\xff\xff"), "\xff\xff", "", ""},
+		{"reversed prefix", db.Model(&account{}).Prefix("u_").Reverse(), "u`", "", "PrefixFilter('u_')"},
+		{"reversed prefix with filter", db.Model(&account{}).Prefix("u_").Reverse().Where(Eq("Email", "a@b.c")), "u`", "", "(PrefixFilter('u_')) AND (" + email + ")"},
+		{"range replaces prefix", db.Model(&account{}).Prefix("u_").Range("a", "m"), "a", "m", ""},
+		{"filters", db.Model(&account{}).Where(Eq("Email", "a@b.c")).Where(KeyOnly()), "", "", "(" + email + ") AND (KeyOnlyFilter())"},
+	}
+	for _, c := range cases {
+		tScan, err := c.query.build()
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if !bytes.Equal(tScan.StartRow, []byte(c.start)) || !bytes.Equal(tScan.StopRow, []byte(c.stop)) {
+			t.Errorf("%s: scans [%q, %q), expected [%q, %q)", c.name, tScan.StartRow, tScan.StopRow, c.start, c.stop)
+		}
+		if string(tScan.FilterString) != c.filter {
+			t.Errorf("%s: filter %s, expected %s", c.name, tScan.FilterString, c.filter)
+		}
+	}
+}
+
+func TestQueryBuildScanOptions(t *testing.T) {
+	db, _ := newFakeDB(true)
+	tScan, err := db.Model(&account{}).Select("Email").Limit(5).Caching(20).MaxVersions(3).Reverse().build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tScan.Limit == nil || *tScan.Limit != 5 {
+		t.Errorf("limit %v, expected 5", tScan.Limit)
+	}
+	if tScan.Caching == nil || *tScan.Caching != 20 {
+		t.Errorf("caching %v, expected 20", tScan.Caching)
+	}
+	if tScan.MaxVersions != 3 {
+		t.Errorf("max versions %d, expected 3", tScan.MaxVersions)
+	}
+	if tScan.Reversed == nil || !*tScan.Reversed {
+		t.Errorf("reversed %v, expected true", tScan.Reversed)
+	}
+	if len(tScan.Columns) != 1 || string(tScan.Columns[0].Family) != "info" || string(tScan.Columns[0].Qualifier) != "email" {
+		t.Errorf("unexpected columns %v", tScan.Columns)
+	}
+
+	tScan, err = db.Model(&account{}).build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tScan.Limit != nil || tScan.Caching != nil || tScan.Reversed != nil || tScan.Columns != nil {
+		t.Errorf("unset options reached the scan: %v", tScan)
+	}
+
+	if _, err = db.Model(&account{}).Select("Missing").build(); !errors.Is(err, ErrUnknownColumn) {
+		t.Errorf("expected ErrUnknownColumn, but got %v", err)
+	}
+}
@@ -0,0 +1,157 @@
+package horm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/challenai/horm/thrift/hbase"
+)
+
+// Rows is a streaming iterator over a range of HBase rows.
+// it holds a server-side scanner, so Close must be called once the iteration is done.
+//
+//	rows, err := db.Rows(ctx, &User{}, startRow, stopRow, nil, nil)
+//	if err != nil {
+//		return err
+//	}
+//	defer rows.Close()
+//	for rows.Next() {
+//		user := &User{}
+//		if err := rows.Scan(user); err != nil {
+//			return err
+//		}
+//	}
+//	return rows.Err()
+type Rows struct {
+	ctx       context.Context
+	h         *DB
+	scannerID int32
+	limit     int32
+	fetched   int32
+	batch     []*hbase.TResult_
+	current   *hbase.TResult_
+	stale     bool // any row was served stale
+	done      bool
+	closed    bool
+	err       error
+	closeErr  error
+}
+
+// open a server-side scanner on the table of model and return an iterator over its rows.
+func (h *DB) Rows(ctx context.Context, model interface{}, startRow, stopRow string, selects []Column, filter *Filter, opts ...Option) (*Rows, error) {
+	tb, err := tableOf(model)
+	if err != nil {
+		return nil, err
+	}
+
+	var limit int32
+	if filter != nil {
+		limit = filter.Limit
+	}
+	tScan := buildScan(startRow, stopRow, selects, filter)
+	h.newOptions(opts).applyScan(tScan)
+	return h.openRows(ctx, tb, tScan, limit)
+}
+
+// open a server-side scanner with a prepared thrift scan, limit <= 0 means no limit.
+func (h *DB) openRows(ctx context.Context, tb Table, tScan *hbase.TScan, limit int32) (*Rows, error) {
+	tx := h.session()
+	scannerID, err := tx.db.OpenScanner(ctx, []byte(fmt.Sprintf("%s:%s", tb.Namespace(), tb.TableName())), tScan)
+	if err != nil {
+		return nil, err
+	}
+	rows := &Rows{
+		ctx:       ctx,
+		h:         tx,
+		scannerID: scannerID,
+		limit:     limit,
+	}
+	return rows, nil
+}
+
+// Next prepares the next row for Scan, it returns false when there are no more rows,
+// an error occurred or the context is canceled, the scanner is closed automatically in that case.
+func (r *Rows) Next() bool {
+	if r.closed {
+		return false
+	}
+	if err := r.ctx.Err(); err != nil {
+		r.err = err
+		r.Close()
+		return false
+	}
+	if len(r.batch) == 0 && !r.done {
+		r.fetch()
+	}
+	if len(r.batch) == 0 {
+		r.Close()
+		return false
+	}
+	r.current = r.batch[0]
+	if r.current.Stale {
+		r.stale = true
+	}
+	r.batch[0] = nil
+	r.batch = r.batch[1:]
+	return true
+}
+
+// fetch the next batch of rows from the server-side scanner.
+func (r *Rows) fetch() {
+	resultSz := BatchResultSize
+	if r.limit > 0 {
+		resultSz = getQuerySize(BatchResultSize, r.limit-r.fetched)
+		if resultSz == 0 {
+			r.done = true
+			return
+		}
+	}
+	results, err := r.h.db.GetScannerRows(r.ctx, r.scannerID, resultSz)
+	if err != nil {
+		r.err = err
+		r.done = true
+		return
+	}
+	if len(results) == 0 {
+		r.done = true
+		return
+	}
+	r.fetched += int32(len(results))
+	r.batch = results
+}
+
+// Scan decodes the current row into model, which should be a pointer to struct, like *User.
+func (r *Rows) Scan(model interface{}) error {
+	if r.current == nil {
+		return errors.New("Scan called without calling Next")
+	}
+	if model == nil || reflect.TypeOf(model).Kind() != reflect.Ptr || reflect.TypeOf(model).Elem().Kind() != reflect.Struct || reflect.ValueOf(model).IsNil() {
+		return fmt.Errorf("%w: model should be a struct pointer, for example: *User", ErrInvalidModel)
+	}
+	value := reflect.ValueOf(model).Elem()
+	return r.h.retrieveValue(&value, r.current)
+}
+
+// Err returns the error, if any, that was encountered during iteration.
+func (r *Rows) Err() error {
+	return r.err
+}
+
+// Close closes the server-side scanner, it's safe to call Close more than once.
+func (r *Rows) Close() error {
+	if r.closed {
+		return r.closeErr
+	}
+	r.closed = true
+	r.batch = nil
+	r.current = nil
+	ctx := r.ctx
+	// the scanner should be released even if the caller's context is already canceled
+	if ctx.Err() != nil {
+		ctx = context.Background()
+	}
+	r.closeErr = r.h.db.CloseScanner(ctx, r.scannerID)
+	return r.closeErr
+}
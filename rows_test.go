@@ -0,0 +1,159 @@
+package horm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/apache/thrift/lib/go/thrift"
+	"github.com/challenai/horm/thrift/hbase"
+)
+
+// fakeScanner serves n account rows through the scanner RPCs and records how they're called.
+type fakeScanner struct {
+	n        int
+	next     int
+	requests []int32 // numRows of every getScannerRows
+	closes   int
+	closeCtx context.Context
+	err      error // returned by getScannerRows
+}
+
+func (s *fakeScanner) answer(ctx context.Context, method string, args, result thrift.TStruct) error {
+	switch r := result.(type) {
+	case *hbase.THBaseServiceOpenScannerResult:
+		id := int32(7)
+		r.Success = &id
+	case *hbase.THBaseServiceGetScannerRowsResult:
+		if s.err != nil {
+			return s.err
+		}
+		numRows := args.(*hbase.THBaseServiceGetScannerRowsArgs).NumRows
+		s.requests = append(s.requests, numRows)
+		r.Success = []*hbase.TResult_{}
+		for ; s.next < s.n && len(r.Success) < int(numRows); s.next++ {
+			r.Success = append(r.Success, &hbase.TResult_{
+				Row:          []byte(fmt.Sprintf("r%03d", s.next)),
+				ColumnValues: []*hbase.TColumnValue{{Family: []byte("info"), Qualifier: []byte("email"), Value: []byte("a@b.c")}},
+			})
+		}
+	case *hbase.THBaseServiceCloseScannerResult:
+		s.closes++
+		s.closeCtx = ctx
+	}
+	return nil
+}
+
+func newFakeRows(t *testing.T, ctx context.Context, n int, filter *Filter) (*Rows, *fakeScanner) {
+	t.Helper()
+	db, fc := newFakeDB(true)
+	s := &fakeScanner{n: n}
+	fc.answer = s.answer
+	rows, err := db.Rows(ctx, &account{}, "", "", nil, filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows, s
+}
+
+func TestRowsFetchesInBatches(t *testing.T) {
+	n := int(BatchResultSize) + 6
+	rows, s := newFakeRows(t, context.Background(), n, nil)
+	i := 0
+	for rows.Next() {
+		m := &account{}
+		if err := rows.Scan(m); err != nil {
+			t.Fatal(err)
+		}
+		if m.Rowkey != fmt.Sprintf("r%03d", i) || m.Email != "a@b.c" {
+			t.Fatalf("row %d is %s %q", i, m.Rowkey, m.Email)
+		}
+		i++
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if i != n {
+		t.Errorf("iterated %d rows, expected %d", i, n)
+	}
+	// the last call returns no rows and ends the iteration
+	if len(s.requests) != 3 || s.requests[0] != BatchResultSize {
+		t.Errorf("getScannerRows requested %v", s.requests)
+	}
+	if s.closes != 1 {
+		t.Errorf("scanner closed %d times, expected once", s.closes)
+	}
+	if err := rows.Close(); err != nil || s.closes != 1 {
+		t.Errorf("second Close returned %v and closed the scanner %d times", err, s.closes)
+	}
+}
+
+func TestRowsTruncatesToLimit(t *testing.T) {
+	rows, s := newFakeRows(t, context.Background(), 100, &Filter{Limit: 10})
+	i := 0
+	for rows.Next() {
+		i++
+	}
+	if i != 10 {
+		t.Errorf("iterated %d rows, expected 10", i)
+	}
+	if len(s.requests) != 1 || s.requests[0] != 10 {
+		t.Errorf("getScannerRows requested %v, expected a single request of 10", s.requests)
+	}
+	if s.closes != 1 {
+		t.Errorf("scanner closed %d times, expected once", s.closes)
+	}
+}
+
+func TestRowsStopsOnCanceledContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	rows, s := newFakeRows(t, ctx, 100, nil)
+	if !rows.Next() {
+		t.Fatal(rows.Err())
+	}
+	cancel()
+	if rows.Next() {
+		t.Error("Next returned a row after the context was canceled")
+	}
+	if !errors.Is(rows.Err(), context.Canceled) {
+		t.Errorf("expected context.Canceled, but got %v", rows.Err())
+	}
+	// the scanner is released with a live context
+	if s.closes != 1 || s.closeCtx.Err() != nil {
+		t.Errorf("scanner closed %d times with context error %v", s.closes, s.closeCtx.Err())
+	}
+}
+
+func TestRowsClosesScannerOnError(t *testing.T) {
+	rows, s := newFakeRows(t, context.Background(), 100, nil)
+	s.err = errors.New("region moved")
+	if rows.Next() {
+		t.Error("Next returned a row after getScannerRows failed")
+	}
+	if rows.Err() != s.err {
+		t.Errorf("expected %v, but got %v", s.err, rows.Err())
+	}
+	if s.closes != 1 {
+		t.Errorf("scanner closed %d times, expected once", s.closes)
+	}
+}
+
+func TestRowsCloseBeforeTheEnd(t *testing.T) {
+	rows, s := newFakeRows(t, context.Background(), 100, nil)
+	if !rows.Next() {
+		t.Fatal(rows.Err())
+	}
+	if err := rows.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if rows.Next() {
+		t.Error("Next returned a row after Close")
+	}
+	if err := rows.Scan(&account{}); err == nil {
+		t.Error("Scan succeeded after Close")
+	}
+	if s.closes != 1 || len(s.requests) != 1 {
+		t.Errorf("scanner closed %d times after %d fetches", s.closes, len(s.requests))
+	}
+}
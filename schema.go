@@ -0,0 +1,286 @@
+package horm
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+
+	"github.com/challenai/horm/codec"
+	"github.com/challenai/horm/thrift/hbase"
+)
+
+// schema used to store struct field and column mapping information,
+// it's built once per model type so decoding a row doesn't need to parse tags or format column names.
+type schema struct {
+	model    int                          // index of the embedded *horm.Model
+	fields   []*field                     // mapped fields in declaration order
+	columns  map[string]map[string]*field // family -> qualifier -> field
+	names    map[string]*field            // go field name -> field
+	version  *field                       // optimistic locking version, nil if the model isn't versioned
+	sentinel *field                       // column telling whether a row exists, used by Create and Update
+	stamps   bool                         // fill Model.FieldTimestamps on reads
+}
+
+// field is a struct field mapped to a HBase column, with its precompiled codec.
+type field struct {
+	name       string
+	index      int
+	typ        reflect.Type
+	family     []byte
+	qualifier  []byte
+	visibility string // cell visibility expression, empty if the column isn't labeled
+	encode     func(reflect.Value) []byte
+	decode     func(reflect.Value, []byte) error
+}
+
+// column returns the field mapped to family:qualifier, or nil if there isn't one.
+func (s *schema) column(family, qualifier string) *field {
+	return s.columns[family][qualifier]
+}
+
+// fieldByName returns the mapped field with the go field name, or nil if there isn't one.
+func (s *schema) fieldByName(name string) *field {
+	return s.names[name]
+}
+
+// get the schema of a model type, register it at the first time.
+func (h *DB) loadSchema(t reflect.Type) (*schema, error) {
+	if schm, ok := h.schemas.Load(t); ok {
+		return schm.(*schema), nil
+	}
+	return h.registerModel(t)
+}
+
+// parse imported model so that we don't need to parse all the model fields everytime.
+func (h *DB) registerModel(t reflect.Type) (*schema, error) {
+	baseField, ok := t.FieldByName(ModelName)
+	if !ok || len(baseField.Index) != 1 || baseField.Type != reflect.TypeOf(&Model{}) {
+		return nil, fmt.Errorf("%w: %s should embed *horm.Model", ErrInvalidModel, t)
+	}
+	schm := &schema{
+		model:   baseField.Index[0],
+		columns: map[string]map[string]*field{},
+		names:   map[string]*field{},
+	}
+	switch opt := baseField.Tag.Get(HBaseTagHint); opt {
+	case "":
+	case "timestamps":
+		schm.stamps = true
+	default:
+		return nil, fmt.Errorf("%w: unknown option %q of %s.%s", ErrBadTag, opt, t, ModelName)
+	}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.Name == ModelName {
+			continue
+		}
+		tagsStr := sf.Tag.Get(HBaseTagHint)
+		if tagsStr == "" || tagsStr == "-" {
+			continue
+		}
+		// reflect can't set unexported fields, decoding into them would panic
+		if sf.PkgPath != "" {
+			return nil, fmt.Errorf("%w: field %s.%s is unexported", ErrBadTag, t, sf.Name)
+		}
+		tagsList := strings.Split(tagsStr, ",")
+		if len(tagsList) < 2 || tagsList[0] == "" || tagsList[1] == "" {
+			return nil, fmt.Errorf("%w: field %s.%s doesn't have column family or qualifier", ErrBadTag, t, sf.Name)
+		}
+		family, qualifier := tagsList[0], tagsList[1]
+		if schm.column(family, qualifier) != nil {
+			return nil, fmt.Errorf("%w: column %s:%s of %s is mapped more than once", ErrBadTag, family, qualifier, t)
+		}
+		encode, decode := fieldCodec(h.cdc, sf.Type)
+		if encode == nil {
+			return nil, fmt.Errorf("%w: field %s.%s has unsupported type %s", ErrBadTag, t, sf.Name, sf.Type)
+		}
+		f := &field{
+			name:      sf.Name,
+			index:     i,
+			typ:       sf.Type,
+			family:    []byte(family),
+			qualifier: []byte(qualifier),
+			encode:    encode,
+			decode:    decode,
+		}
+		schm.fields = append(schm.fields, f)
+		if schm.columns[family] == nil {
+			schm.columns[family] = map[string]*field{}
+		}
+		schm.columns[family][qualifier] = f
+		schm.names[f.name] = f
+
+		for _, opt := range tagsList[2:] {
+			switch {
+			case strings.HasPrefix(opt, visibilityOption):
+				f.visibility = strings.TrimPrefix(opt, visibilityOption)
+				if f.visibility == "" {
+					return nil, fmt.Errorf("%w: empty visibility of field %s.%s", ErrBadTag, t, sf.Name)
+				}
+			case opt == "version":
+				if schm.version != nil {
+					return nil, fmt.Errorf("%w: %s has more than one version field", ErrBadTag, t)
+				}
+				if !isInteger(sf.Type) {
+					return nil, fmt.Errorf("%w: version field %s.%s should be an integer", ErrBadTag, t, sf.Name)
+				}
+				schm.version = f
+			case opt == "sentinel":
+				if schm.sentinel != nil {
+					return nil, fmt.Errorf("%w: %s has more than one sentinel field", ErrBadTag, t)
+				}
+				schm.sentinel = f
+			default:
+				return nil, fmt.Errorf("%w: unknown option %q of field %s.%s", ErrBadTag, opt, t, sf.Name)
+			}
+		}
+	}
+	// the sentinel defaults to the version field, then the first mapped field
+	if schm.sentinel == nil {
+		schm.sentinel = schm.version
+	}
+	if schm.sentinel == nil && len(schm.fields) > 0 {
+		schm.sentinel = schm.fields[0]
+	}
+	// another goroutine may have registered the same model meanwhile, keep the first one
+	actual, _ := h.schemas.LoadOrStore(t, schm)
+	return actual.(*schema), nil
+}
+
+// check whether t is an integer type.
+func isInteger(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return true
+	}
+	return false
+}
+
+// build encoder and decoder of a field type, return nil if the type isn't supported.
+func fieldCodec(cdc codec.Codec, t reflect.Type) (func(reflect.Value) []byte, func(reflect.Value, []byte) error) {
+	switch t.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(v reflect.Value) []byte {
+				return cdc.EncodeInt(v.Int())
+			}, func(v reflect.Value, b []byte) error {
+				n, err := cdc.DecodeInt(b)
+				if err == nil {
+					v.SetInt(n)
+				}
+				return err
+			}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return func(v reflect.Value) []byte {
+				return cdc.EncodeUint(v.Uint())
+			}, func(v reflect.Value, b []byte) error {
+				n, err := cdc.DecodeUint(b)
+				if err == nil {
+					v.SetUint(n)
+				}
+				return err
+			}
+	case reflect.Float32, reflect.Float64:
+		return func(v reflect.Value) []byte {
+				return cdc.EncodeFloat(v.Float())
+			}, func(v reflect.Value, b []byte) error {
+				n, err := cdc.DecodeFloat(b)
+				if err == nil {
+					v.SetFloat(n)
+				}
+				return err
+			}
+	case reflect.String:
+		return func(v reflect.Value) []byte {
+				return cdc.EncodeString(v.String())
+			}, func(v reflect.Value, b []byte) error {
+				s, err := cdc.DecodeString(b)
+				if err == nil {
+					v.SetString(s)
+				}
+				return err
+			}
+	case reflect.Bool:
+		return func(v reflect.Value) []byte {
+				return cdc.EncodeBool(v.Bool())
+			}, func(v reflect.Value, b []byte) error {
+				x, err := cdc.DecodeBool(b)
+				if err == nil {
+					v.SetBool(x)
+				}
+				return err
+			}
+	case reflect.Slice:
+		// []byte is stored as it is
+		if t.Elem().Kind() != reflect.Uint8 {
+			break
+		}
+		return func(v reflect.Value) []byte {
+				return v.Bytes()
+			}, func(v reflect.Value, b []byte) error {
+				v.SetBytes(append([]byte{}, b...))
+				return nil
+			}
+	}
+	return nil, nil
+}
+
+// encode the field of a model value to a thrift column value.
+func (f *field) columnValue(model reflect.Value) *hbase.TColumnValue {
+	return &hbase.TColumnValue{
+		Family:    f.family,
+		Qualifier: f.qualifier,
+		Value:     f.encode(model.Field(f.index)),
+	}
+}
+
+// encode a value with the codec of the field, value should be convertible to the field type without loss.
+func (f *field) encodeValue(value interface{}) ([]byte, error) {
+	v := reflect.ValueOf(value)
+	// numbers are convertible to string in go, but they mean runes rather than text
+	if !v.IsValid() || !v.Type().ConvertibleTo(f.typ) || (f.typ.Kind() == reflect.String) != (v.Kind() == reflect.String) {
+		return nil, fmt.Errorf("value %v of field %s should be convertible to %s", value, f.name, f.typ)
+	}
+	if !convertsExactly(v, f.typ) {
+		return nil, fmt.Errorf("value %v of field %s can't be converted to %s without loss", value, f.name, f.typ)
+	}
+	return f.encode(v.Convert(f.typ)), nil
+}
+
+// whether a number keeps its value when converted to t, other values always do.
+func convertsExactly(v reflect.Value, t reflect.Type) bool {
+	target := reflect.New(t).Elem()
+	switch {
+	case isInteger(v.Type()) && isInteger(t):
+		if isSigned(v.Kind()) {
+			n := v.Int()
+			if isSigned(t.Kind()) {
+				return !target.OverflowInt(n)
+			}
+			return n >= 0 && !target.OverflowUint(uint64(n))
+		}
+		n := v.Uint()
+		if isSigned(t.Kind()) {
+			return n <= math.MaxInt64 && !target.OverflowInt(int64(n))
+		}
+		return !target.OverflowUint(n)
+	case isInteger(v.Type()) && isFloat(t):
+		// integers beyond the mantissa lose their low bits
+		converted := v.Convert(t)
+		return converted.Convert(v.Type()).Interface() == v.Interface() && !target.OverflowFloat(converted.Float())
+	case isFloat(v.Type()) && isInteger(t):
+		return false
+	case isFloat(v.Type()) && isFloat(t):
+		return !target.OverflowFloat(v.Float())
+	}
+	return true
+}
+
+func isSigned(k reflect.Kind) bool {
+	return k >= reflect.Int && k <= reflect.Int64
+}
+
+func isFloat(t reflect.Type) bool {
+	return t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64
+}
@@ -0,0 +1,136 @@
+package horm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/challenai/horm/thrift/hbase"
+)
+
+// read a row into model, run fn to modify model, then write the changed fields back only if the row
+// still holds the values read. it's retried with backoff when the row changed underneath:
+//
+//	user := &User{}
+//	err := db.Transact(ctx, user, rowkey, func() error {
+//		user.Balance -= 10
+//		return nil
+//	}, horm.WithRetries(5, 20*time.Millisecond)).Error
+//
+// the write is conditioned on the version field of model, which guards the whole row, so model must
+// have one, see the version tag option. an error returned by fn aborts the transaction,
+// ErrStaleObject is returned if all the retries conflict.
+func (h *DB) Transact(ctx context.Context, model interface{}, rowkey string, fn func() error, opts ...Option) *DB {
+	tx := h.session()
+	o := h.newOptions(opts)
+	tb, err := tableOf(model)
+	if err != nil {
+		tx.Error = err
+		return tx
+	}
+	value := reflect.ValueOf(model).Elem()
+	schm, err := h.loadSchema(value.Type())
+	if err != nil {
+		tx.Error = err
+		return tx
+	}
+	// fn may depend on any column it read, only a version guards them all
+	if schm.version == nil {
+		tx.Error = fmt.Errorf("%w: %T needs a version field to be written by Transact", ErrInvalidModel, model)
+		return tx
+	}
+
+	table := []byte(fmt.Sprintf("%s:%s", tb.Namespace(), tb.TableName()))
+	backoff := o.backoff
+	for attempt := 0; ; attempt++ {
+		var written bool
+		written, err = tx.transactOnce(ctx, table, schm, value, rowkey, fn, o)
+		if written {
+			tx.RowsAffected = 1
+		}
+		if !errors.Is(err, ErrStaleObject) || attempt >= o.retries {
+			break
+		}
+		timer := time.NewTimer(backoff)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			tx.Error = ctx.Err()
+			return tx
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+	tx.Error = err
+	return tx
+}
+
+// run a single read-modify-write round, it returns whether the row is written.
+func (h *DB) transactOnce(ctx context.Context, table []byte, schm *schema, value reflect.Value, rowkey string, fn func() error, o *options) (bool, error) {
+	row := []byte(rowkey)
+	tGet := &hbase.TGet{Row: row}
+	o.applyGet(tGet)
+	result, err := h.db.Get(ctx, table, tGet)
+	if err != nil {
+		return false, err
+	}
+
+	// reset the fields so a retry doesn't see the changes of the last round
+	for _, f := range schm.fields {
+		value.Field(f.index).Set(reflect.Zero(f.typ))
+	}
+	value.Field(schm.model).Set(reflect.ValueOf(&Model{
+		Rowkey: rowkey,
+	}))
+	if result != nil && len(result.ColumnValues) > 0 {
+		if err = h.retrieveValue(&value, result); err != nil {
+			return false, err
+		}
+	}
+	read := make([][]byte, len(schm.fields))
+	for i, f := range schm.fields {
+		read[i] = f.encode(value.Field(f.index))
+	}
+	version := reflect.New(schm.version.typ).Elem()
+	version.Set(value.Field(schm.version.index))
+
+	if err = fn(); err != nil {
+		return false, err
+	}
+
+	put := &hbase.TPut{Row: row}
+	for i, f := range schm.fields {
+		if f == schm.version {
+			continue
+		}
+		now := f.encode(value.Field(f.index))
+		if bytes.Equal(now, read[i]) {
+			continue
+		}
+		put.ColumnValues = append(put.ColumnValues, &hbase.TColumnValue{
+			Family:    f.family,
+			Qualifier: f.qualifier,
+			Value:     now,
+		})
+	}
+	if len(put.ColumnValues) == 0 {
+		return false, nil
+	}
+	o.applyPut(put, value)
+	mutations := &hbase.TRowMutations{
+		Row:       row,
+		Mutations: []*hbase.TMutation{{Put: put}},
+	}
+
+	// the version is managed by horm, ignore the changes made by fn
+	value.Field(schm.version.index).Set(version)
+	next := nextVersion(schm.version, value, put)
+	if err = h.checkVersionAndMutate(ctx, table, schm, value, mutations); err != nil {
+		return false, err
+	}
+	value.Field(schm.version.index).Set(next)
+	return true, nil
+}
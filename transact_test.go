@@ -0,0 +1,47 @@
+package horm
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/challenai/horm/codec"
+	"github.com/challenai/horm/thrift/hbase"
+)
+
+func TestTransactRequiresVersion(t *testing.T) {
+	db, fc := newFakeDB(true)
+	err := db.Transact(context.Background(), &account{}, "a", func() error { return nil }).Error
+	if !errors.Is(err, ErrInvalidModel) {
+		t.Errorf("expected ErrInvalidModel, but got %v", err)
+	}
+	if len(fc.calls) != 0 {
+		t.Errorf("expected no call, but got %v", fc.calls)
+	}
+}
+
+func TestTransactChecksVersion(t *testing.T) {
+	db, fc := newFakeDB(true)
+	m := &versionedAccount{}
+	err := db.Transact(context.Background(), m, "a", func() error {
+		m.Email = "a@b.c"
+		return nil
+	}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := fc.last(t, "checkAndMutate").(*hbase.THBaseServiceCheckAndMutateArgs)
+	// the row didn't exist, so its version column should still be absent
+	if string(args.Qualifier) != "ver" || args.CompareOperator != hbase.TCompareOperator_EQUAL || args.Value != nil {
+		t.Errorf("condition on %s %s %v, expected ver to be absent", args.Qualifier, args.CompareOperator, args.Value)
+	}
+	put := args.RowMutations.Mutations[0].Put
+	if email := columnOf(put, "info", "email"); string(email) != "a@b.c" {
+		t.Errorf("wrote email %q, expected a@b.c", email)
+	}
+	c := &codec.DefaultCodec{}
+	if ver := columnOf(put, "info", "ver"); !bytes.Equal(ver, c.EncodeInt(1)) || m.Ver != 1 {
+		t.Errorf("wrote version %v and model has %d, expected 1", ver, m.Ver)
+	}
+}
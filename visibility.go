@@ -0,0 +1,94 @@
+package horm
+
+import (
+	"fmt"
+
+	"github.com/challenai/horm/thrift/hbase"
+)
+
+// tag option labeling a column with a cell visibility expression:
+//
+//	type User struct {
+//		*horm.Model
+//		Name  string `horm:"info,name"`
+//		Phone string `horm:"pii,phone,visibility=PII&!CONTRACTOR"`
+//	}
+const visibilityOption = "visibility="
+
+// a put carries a single visibility expression, so the columns of put are split into a put per
+// expression of their fields. put is returned as is if it already has a visibility.
+func (s *schema) splitPut(put *hbase.TPut) []*hbase.TPut {
+	if put.CellVisibility != nil || len(put.ColumnValues) == 0 {
+		return []*hbase.TPut{put}
+	}
+	var puts []*hbase.TPut
+	byExpr := map[string]*hbase.TPut{}
+	for _, col := range put.ColumnValues {
+		var expr string
+		if f := s.columns[string(col.Family)][string(col.Qualifier)]; f != nil {
+			expr = f.visibility
+		}
+		p := byExpr[expr]
+		if p == nil {
+			p = &hbase.TPut{
+				Row:        put.Row,
+				Timestamp:  put.Timestamp,
+				Attributes: put.Attributes,
+				Durability: put.Durability,
+			}
+			if expr != "" {
+				p.CellVisibility = &hbase.TCellVisibility{Expression: &expr}
+			}
+			byExpr[expr] = p
+			puts = append(puts, p)
+		}
+		p.ColumnValues = append(p.ColumnValues, col)
+	}
+	if len(puts) == 1 {
+		put.CellVisibility = puts[0].CellVisibility
+		return []*hbase.TPut{put}
+	}
+	return puts
+}
+
+// split the puts of mutations by visibility, the mutations of a row are still applied atomically.
+func (s *schema) splitMutations(mutations *hbase.TRowMutations) {
+	split := make([]*hbase.TMutation, 0, len(mutations.Mutations))
+	for _, m := range mutations.Mutations {
+		if m.Put == nil {
+			split = append(split, m)
+			continue
+		}
+		for _, put := range s.splitPut(m.Put) {
+			split = append(split, &hbase.TMutation{Put: put})
+		}
+	}
+	mutations.Mutations = split
+}
+
+// the visibility shared by fields, which are written by a single increment or append.
+func sharedVisibility(fields []*field) (*hbase.TCellVisibility, error) {
+	var expr string
+	for i, f := range fields {
+		if i > 0 && f.visibility != expr {
+			return nil, fmt.Errorf("fields %s and %s have different visibilities, write them separately", fields[0].name, f.name)
+		}
+		expr = f.visibility
+	}
+	if expr == "" {
+		return nil, nil
+	}
+	return &hbase.TCellVisibility{Expression: &expr}, nil
+}
+
+// wrap puts of the same row into row mutations.
+func putMutations(row []byte, puts []*hbase.TPut) *hbase.TRowMutations {
+	mutations := &hbase.TRowMutations{
+		Row:       row,
+		Mutations: make([]*hbase.TMutation, 0, len(puts)),
+	}
+	for _, put := range puts {
+		mutations.Mutations = append(mutations.Mutations, &hbase.TMutation{Put: put})
+	}
+	return mutations
+}
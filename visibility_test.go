@@ -0,0 +1,140 @@
+package horm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/challenai/horm/thrift/hbase"
+)
+
+type profile struct {
+	*Model
+	Name  string `horm:"info,name"`
+	Phone string `horm:"pii,phone,visibility=PII"`
+	SSN   string `horm:"pii,ssn,visibility=PII&!CONTRACTOR"`
+	Views int64  `horm:"info,views"`
+	Hits  int64  `horm:"pii,hits,visibility=PII"`
+}
+
+func (*profile) Namespace() string { return "test" }
+func (*profile) TableName() string { return "profile" }
+
+func newProfile() *profile {
+	return &profile{Model: &Model{Rowkey: "a"}, Name: "a", Phone: "1", SSN: "2"}
+}
+
+// the visibility expression of a put, empty if it has none.
+func visibilityOf(put *hbase.TPut) string {
+	if put.CellVisibility == nil {
+		return ""
+	}
+	return put.CellVisibility.GetExpression()
+}
+
+// the qualifiers of the columns of a put.
+func qualifiersOf(put *hbase.TPut) []string {
+	var qualifiers []string
+	for _, col := range put.ColumnValues {
+		qualifiers = append(qualifiers, string(col.Qualifier))
+	}
+	return qualifiers
+}
+
+func TestSetSplitsPutByVisibility(t *testing.T) {
+	db, fc := newFakeDB(true)
+	ctx := context.Background()
+	if err := db.Set(ctx, newProfile(), nil).Error; err != nil {
+		t.Fatal(err)
+	}
+	mutations := fc.last(t, "mutateRow").(*hbase.THBaseServiceMutateRowArgs).TrowMutations.Mutations
+	expected := []struct {
+		visibility string
+		qualifiers string
+	}{
+		{"", "[name views]"},
+		{"PII", "[phone hits]"},
+		{"PII&!CONTRACTOR", "[ssn]"},
+	}
+	if len(mutations) != len(expected) {
+		t.Fatalf("%d mutations, expected %d", len(mutations), len(expected))
+	}
+	for i, e := range expected {
+		put := mutations[i].Put
+		if visibilityOf(put) != e.visibility || fmt.Sprint(qualifiersOf(put)) != e.qualifiers {
+			t.Errorf("put %d writes %v with %q, expected %s with %q", i, qualifiersOf(put), visibilityOf(put), e.qualifiers, e.visibility)
+		}
+	}
+
+	// columns sharing a single visibility stay a single put
+	selects := []Column{{Family: "pii", Name: "phone"}, {Family: "pii", Name: "hits"}}
+	if err := db.Set(ctx, newProfile(), selects).Error; err != nil {
+		t.Fatal(err)
+	}
+	if put := fc.last(t, "put").(*hbase.THBaseServicePutArgs).Tput; visibilityOf(put) != "PII" || len(put.ColumnValues) != 2 {
+		t.Errorf("put writes %v with %q, expected [phone hits] with PII", qualifiersOf(put), visibilityOf(put))
+	}
+	selects = []Column{{Family: "info", Name: "name"}}
+	if err := db.Set(ctx, newProfile(), selects).Error; err != nil {
+		t.Fatal(err)
+	}
+	if put := fc.last(t, "put").(*hbase.THBaseServicePutArgs).Tput; put.CellVisibility != nil {
+		t.Errorf("unlabeled put has visibility %q", visibilityOf(put))
+	}
+}
+
+func TestWithVisibilityOverridesTags(t *testing.T) {
+	db, fc := newFakeDB(true)
+	ctx := context.Background()
+	if err := db.Set(ctx, newProfile(), nil, WithVisibility("SECRET")).Error; err != nil {
+		t.Fatal(err)
+	}
+	if put := fc.last(t, "put").(*hbase.THBaseServicePutArgs).Tput; visibilityOf(put) != "SECRET" || len(put.ColumnValues) != 5 {
+		t.Errorf("put writes %v with %q, expected all the columns with SECRET", qualifiersOf(put), visibilityOf(put))
+	}
+
+	deltas := map[string]int64{"Views": 1, "Hits": 1}
+	if err := db.Increment(ctx, &profile{}, "a", deltas, false, WithVisibility("SECRET")).Error; err != nil {
+		t.Fatal(err)
+	}
+	if inc := fc.last(t, "increment").(*hbase.THBaseServiceIncrementArgs).Tincrement; inc.CellVisibility.GetExpression() != "SECRET" {
+		t.Errorf("increment has visibility %v, expected SECRET", inc.CellVisibility)
+	}
+}
+
+func TestCreateSplitsPutByVisibility(t *testing.T) {
+	db, fc := newFakeDB(true)
+	if err := db.Create(context.Background(), newProfile(), nil).Error; err != nil {
+		t.Fatal(err)
+	}
+	args := fc.last(t, "checkAndMutate").(*hbase.THBaseServiceCheckAndMutateArgs)
+	if string(args.Qualifier) != "name" || args.Value != nil {
+		t.Errorf("condition on %s = %q, expected name to be absent", args.Qualifier, args.Value)
+	}
+	if n := len(args.RowMutations.Mutations); n != 3 {
+		t.Errorf("%d mutations, expected a put per visibility", n)
+	}
+}
+
+func TestIncrementSharesVisibility(t *testing.T) {
+	db, fc := newFakeDB(true)
+	ctx := context.Background()
+	if err := db.Increment(ctx, &profile{}, "a", map[string]int64{"Views": 1, "Hits": 1}, false).Error; err == nil {
+		t.Error("increment of fields with different visibilities should fail")
+	}
+	if len(fc.calls) != 0 {
+		t.Errorf("unexpected calls %v", fc.calls)
+	}
+	if err := db.Increment(ctx, &profile{}, "a", map[string]int64{"Hits": 1}, false).Error; err != nil {
+		t.Fatal(err)
+	}
+	if inc := fc.last(t, "increment").(*hbase.THBaseServiceIncrementArgs).Tincrement; inc.CellVisibility.GetExpression() != "PII" {
+		t.Errorf("increment has visibility %v, expected PII", inc.CellVisibility)
+	}
+	if err := db.Increment(ctx, &profile{}, "a", map[string]int64{"Views": 1}, false).Error; err != nil {
+		t.Fatal(err)
+	}
+	if inc := fc.last(t, "increment").(*hbase.THBaseServiceIncrementArgs).Tincrement; inc.CellVisibility != nil {
+		t.Errorf("unlabeled increment has visibility %v", inc.CellVisibility)
+	}
+}